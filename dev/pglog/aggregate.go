@@ -0,0 +1,236 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Aggregation Windows ─────────────────────────────────────────────
+// High-frequency analog tags can be summarised instead of logged on every
+// change:
+//
+//	"aggregate": {"window_seconds": 60, "tags": ["temperature", "pressure"]}
+//
+// Every invocation adds the current value of each aggregated tag to an
+// in-memory accumulator for the window it falls in (windows are aligned
+// to the epoch, so 60 s windows start on the minute). When an invocation
+// falls in a later window, the finished window is written as
+// one row with logged_at = window start, tag = "_aggregate", changed =
+// the aggregated tags and per-tag stats in values:
+//
+//	{"temperature": {"min": 21.9, "max": 23.4, "avg": 22.6, "count": 12, "last": 23.1}}
+//
+// Typed columns get the window's avg. Aggregated tags never trigger a
+// change row; the other tags keep the change-detection path. Non-numeric
+// values are ignored. Accumulators live in process memory, so an
+// unfinished window is lost on restart and replicas aggregate separately.
+
+const aggregateTag = "_aggregate"
+
+type aggregateConfig struct {
+	WindowSeconds float64  `json:"window_seconds"`
+	Tags          []string `json:"tags"`
+}
+
+// aggregateStats is the summary of one tag over one window.
+type aggregateStats struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+	Last  float64 `json:"last"`
+}
+
+type tagAccumulator struct {
+	Window time.Time // start of the window being accumulated
+	Min    float64
+	Max    float64
+	Sum    float64
+	Count  int
+	Last   float64
+}
+
+var (
+	accumulatorsMu sync.Mutex
+	accumulators   = make(map[string]*tagAccumulator) // stateKey → accumulator
+)
+
+func validateAggregate(agg *aggregateConfig, edges map[string]string) error {
+	if agg == nil {
+		return nil
+	}
+	if agg.WindowSeconds <= 0 {
+		return errors.New("aggregate.window_seconds must be positive")
+	}
+	if len(agg.Tags) == 0 {
+		return errors.New("aggregate.tags must list at least one tag")
+	}
+	for _, tag := range agg.Tags {
+		if tag == "" {
+			return errors.New("aggregate.tags must not contain empty tags")
+		}
+		if _, ok := edges[tag]; ok {
+			return fmt.Errorf("aggregate.tags: %s is an edge tag", tag)
+		}
+	}
+	return nil
+}
+
+// aggregates reports whether a tag is summarised per window.
+func (c *pglogConfig) aggregates(tag string) bool {
+	if c.Aggregate == nil {
+		return false
+	}
+	for _, t := range c.Aggregate.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// accumulateWindows adds the aggregated tags' current values to their
+// windows and returns a row for every window that finished before now.
+func accumulateWindows(config *pglogConfig, snapshot map[string]*topicSnapshot, now time.Time) []logRow {
+	window := time.Duration(config.Aggregate.WindowSeconds * float64(time.Second))
+	start := now.Truncate(window)
+
+	finished := make(map[time.Time]map[string]interface{})
+
+	accumulatorsMu.Lock()
+	for _, topic := range config.Topics {
+		tag := config.parseTopic(topic).Tag
+		if !config.aggregates(tag) {
+			continue
+		}
+
+		// A window finishes once a later one starts, whether or not its
+		// topic still reports
+		key := config.stateKey(topic)
+		acc := accumulators[key]
+		if acc != nil && acc.Window.Before(start) {
+			if finished[acc.Window] == nil {
+				finished[acc.Window] = make(map[string]interface{})
+			}
+			finished[acc.Window][tag] = acc.stats()
+			delete(accumulators, key)
+			acc = nil
+		}
+
+		snap := snapshot[topic]
+		if snap == nil || snap.Current == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(config.compareValue(snap.Current)), 64)
+		if err != nil {
+			continue
+		}
+		if acc == nil {
+			acc = &tagAccumulator{Window: start, Min: v, Max: v}
+			accumulators[key] = acc
+		}
+		acc.add(v)
+	}
+	accumulatorsMu.Unlock()
+
+	var rows []logRow
+	for windowStart, values := range finished {
+		changed := make([]string, 0, len(values))
+		for tag := range values {
+			changed = append(changed, tag)
+		}
+		sort.Strings(changed)
+
+		rows = append(rows, logRow{
+			Config:   config,
+			UNS:      config.parseTopic(config.Topics[0]),
+			Tag:      aggregateTag,
+			Values:   values,
+			Changed:  changed,
+			LoggedAt: windowStart,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].LoggedAt.Before(rows[j].LoggedAt)
+	})
+	return rows
+}
+
+func (a *tagAccumulator) add(v float64) {
+	a.Min = min(a.Min, v)
+	a.Max = max(a.Max, v)
+	a.Sum += v
+	a.Count++
+	a.Last = v
+}
+
+func (a *tagAccumulator) stats() aggregateStats {
+	return aggregateStats{
+		Min:   a.Min,
+		Max:   a.Max,
+		Avg:   a.Sum / float64(a.Count),
+		Count: a.Count,
+		Last:  a.Last,
+	}
+}
+
+// insertAggregate is the insertRow variant for window rows: they carry
+// stats rather than a change, so they aren't published as notifications.
+func insertAggregate(ctx context.Context, row *logRow) error {
+	query, args, err := buildInsert(*row)
+	if err != nil {
+		return err
+	}
+	err = writePending(ctx, row.Config, row.Pending, row.Written, func(target *pglogConfig) error {
+		pool, err := dbFor(target)
+		if err != nil {
+			return err
+		}
+		if row.Config.Partition == partitionMonthly {
+			if _, err := pool.Exec(ctx, partitionDDL(row.Config.Table, row.LoggedAt)); err != nil {
+				return fmt.Errorf("failed to create partition: %w", err)
+			}
+		}
+		if _, err := pool.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert aggregate row: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		markPartialWrite(row, err)
+		return err
+	}
+	logInserted(ctx, *row)
+	return nil
+}
+
+// logAggregates inserts finished windows one by one and returns how many
+// were written. Failed ones go to the dead letters when enabled.
+func logAggregates(ctx context.Context, rows []logRow) int {
+	dbCtx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	written := 0
+	for _, row := range rows {
+		err := dbWrite(func() error { return insertAggregate(dbCtx, &row) })
+		if err != nil {
+			metricInsertErrors.Inc()
+			if deadLetterPrefix != "" {
+				if _, dlErr := writeDeadLetter(ctx, row, err); dlErr == nil {
+					continue
+				}
+			}
+			logger.ErrorContext(ctx, "Failed to log aggregate window", "table", row.Config.Table, "window", row.LoggedAt, "error", err)
+			continue
+		}
+		countInserted(ctx, 1)
+		written++
+	}
+	return written
+}
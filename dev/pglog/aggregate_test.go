@@ -0,0 +1,56 @@
+package function
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccumulateWindows(t *testing.T) {
+	const temp = "v1.0/acme/plant1/press/line1/temp"
+	const rpm = "v1.0/acme/plant1/press/line1/rpm"
+	body := `{"topics": ["` + temp + `", "` + rpm + `"], "aggregate": {"window_seconds": 60, "tags": ["temp", "rpm"]}}`
+	a := testConfig(t, `{"name": "agg_a", `+body[1:])
+	b := testConfig(t, `{"name": "agg_b", `+body[1:])
+	t.Cleanup(func() {
+		accumulatorsMu.Lock()
+		defer accumulatorsMu.Unlock()
+		for _, config := range []*pglogConfig{a, b} {
+			for _, topic := range config.Topics {
+				delete(accumulators, config.stateKey(topic))
+			}
+		}
+	})
+
+	minute := time.Date(2026, 2, 10, 14, 30, 0, 0, time.UTC)
+	snap := func(values map[string]string) map[string]*topicSnapshot {
+		s := make(map[string]*topicSnapshot)
+		for topic, v := range values {
+			s[topic] = &topicSnapshot{Current: v}
+		}
+		return s
+	}
+
+	// Configs sharing the table keep their own windows
+	accumulateWindows(a, snap(map[string]string{temp: "20", rpm: "100"}), minute.Add(10*time.Second))
+	accumulateWindows(b, snap(map[string]string{temp: "90", rpm: "900"}), minute.Add(10*time.Second))
+	accumulateWindows(a, snap(map[string]string{temp: "22", rpm: "100"}), minute.Add(20*time.Second))
+
+	// rpm stops reporting; its window still finishes with the next one
+	rows := accumulateWindows(a, snap(map[string]string{temp: "25"}), minute.Add(70*time.Second))
+	if len(rows) != 1 || !rows[0].LoggedAt.Equal(minute) {
+		t.Fatalf("rows = %+v, want one row for %v", rows, minute)
+	}
+	tempStats, _ := rows[0].Values["temp"].(aggregateStats)
+	if tempStats != (aggregateStats{Min: 20, Max: 22, Avg: 21, Count: 2, Last: 22}) {
+		t.Errorf("temp stats = %+v", tempStats)
+	}
+	if rpmStats, _ := rows[0].Values["rpm"].(aggregateStats); rpmStats.Count != 2 || rpmStats.Max != 100 {
+		t.Errorf("rpm stats = %+v, want the silent topic's window written", rpmStats)
+	}
+
+	// The silent topic has no window open in the next minute
+	rows = accumulateWindows(a, snap(nil), minute.Add(130*time.Second))
+	if len(rows) != 1 || rows[0].Values["rpm"] != nil {
+		t.Errorf("rows = %+v, want only temp's second window", rows)
+	}
+}
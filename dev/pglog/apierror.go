@@ -0,0 +1,109 @@
+package function
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ── Error Responses ─────────────────────────────────────────────────
+// Every error response has the same shape, with a stable code that
+// clients and alerts can key off:
+//
+//	{"error": {"code": "CACHE_READ_FAILED", "message": "Failed to read cache", "detail": "dial tcp ...: connection refused"}}
+//
+// message is the fixed description of the code and detail the underlying
+// error, if any. A code never changes meaning once released.
+
+const (
+	codeUnauthorized     = "UNAUTHORIZED"
+	codeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	codeBadRequest       = "BAD_REQUEST"
+	codeNotEnabled       = "NOT_ENABLED"
+	codeNotFound         = "NOT_FOUND"
+	codeConfigLoad       = "CONFIG_LOAD_FAILED"
+	codeConfigInvalid    = "CONFIG_INVALID"
+	codeNoTopics         = "NO_TOPICS"
+	codeTopicExpand      = "TOPIC_EXPAND_FAILED"
+	codeCacheRead        = "CACHE_READ_FAILED"
+	codeTopicsMissing    = "TOPICS_MISSING"
+	codeEnsureTable      = "ENSURE_TABLE_FAILED"
+	codeInsert           = "INSERT_FAILED"
+	codeCircuitOpen      = "CIRCUIT_OPEN"
+	codeRateLimited      = "RATE_LIMITED"
+	codeLineBusy         = "LINE_BUSY"
+	codeInFlight         = "IDEMPOTENCY_IN_FLIGHT"
+	codeQuery            = "QUERY_FAILED"
+	codeDeadLetterList   = "DEADLETTER_LIST_FAILED"
+	codeSnapshotExport   = "SNAPSHOT_EXPORT_FAILED"
+	codeExport           = "EXPORT_FAILED"
+)
+
+var errorMessages = map[string]string{
+	codeUnauthorized:     "Unauthorized",
+	codeMethodNotAllowed: "Method not allowed",
+	codeBadRequest:       "Invalid request",
+	codeNotEnabled:       "Feature not enabled",
+	codeNotFound:         "Not found",
+	codeConfigLoad:       "Failed to load config",
+	codeConfigInvalid:    "Invalid config",
+	codeNoTopics:         "No topics configured",
+	codeTopicExpand:      "Failed to expand topics",
+	codeCacheRead:        "Failed to read cache",
+	codeTopicsMissing:    "Topics missing from cache",
+	codeEnsureTable:      "Failed to ensure table",
+	codeInsert:           "Failed to insert row",
+	codeCircuitOpen:      "Postgres circuit breaker open",
+	codeRateLimited:      "Rate limit exceeded",
+	codeLineBusy:         "Gave up waiting for the line",
+	codeInFlight:         "A request with this idempotency key is running",
+	codeQuery:            "Failed to query rows",
+	codeDeadLetterList:   "Failed to list dead letters",
+	codeSnapshotExport:   "Failed to export snapshot",
+	codeExport:           "Failed to export rows",
+}
+
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+func newAPIErrorBody(code string, err error) apiErrorBody {
+	body := apiErrorBody{Code: code, Message: errorMessages[code]}
+	if err != nil {
+		body.Detail = err.Error()
+	}
+	return body
+}
+
+// apiError returns the status and body of an error response. err (may be
+// nil) becomes the detail and can override status, see errorStatus. A
+// write rejected by the circuit breaker is reported as CIRCUIT_OPEN. The
+// body is a map so callers can add fields next to "error".
+func apiError(code string, status int, err error) (int, map[string]interface{}) {
+	resp := make(map[string]interface{})
+	if errors.Is(err, errCircuitOpen) {
+		code = codeCircuitOpen
+		resp["circuit"] = circuitOpen
+	}
+	if err != nil {
+		status = errorStatus(err, status)
+	}
+	resp["error"] = newAPIErrorBody(code, err)
+	return status, resp
+}
+
+// configError is apiError for loadConfig failures, telling invalid
+// configs (400) from S3 failures.
+func configError(err error) (int, map[string]interface{}) {
+	if errors.Is(err, errInvalidConfig) {
+		return apiError(codeConfigInvalid, http.StatusBadRequest, err)
+	}
+	return apiError(codeConfigLoad, http.StatusInternalServerError, err)
+}
+
+// writeError writes an apiError response.
+func writeError(w http.ResponseWriter, code string, status int, err error) {
+	status, body := apiError(code, status, err)
+	writeJSON(w, status, body)
+}
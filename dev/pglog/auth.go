@@ -0,0 +1,46 @@
+package function
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ── Authentication ──────────────────────────────────────────────────
+// When AUTH_TOKEN is set every request must carry
+//
+//	Authorization: Bearer <token>
+//
+// Sub-paths listed in AUTH_SKIP_PATHS (comma-separated, e.g.
+// "health,metrics") stay open for probes and scrapers. Without
+// AUTH_TOKEN the function is unauthenticated, as before.
+
+var (
+	authToken     = envOrDefault("AUTH_TOKEN", "")
+	authSkipPaths = parseAuthSkipPaths(envOrDefault("AUTH_SKIP_PATHS", ""))
+)
+
+func parseAuthSkipPaths(raw string) map[string]bool {
+	paths := make(map[string]bool)
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.Trim(strings.TrimSpace(p), "/")
+		if p != "" {
+			paths[p] = true
+		}
+	}
+	return paths
+}
+
+// authorized reports whether the request may proceed.
+func authorized(r *http.Request) bool {
+	if authToken == "" || authSkipPaths[path.Base(r.URL.Path)] {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(authToken)) == 1
+}
@@ -0,0 +1,66 @@
+package function
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorized(t *testing.T) {
+	oldToken, oldSkip := authToken, authSkipPaths
+	authToken, authSkipPaths = "s3cret", parseAuthSkipPaths(" health, /metrics/ ,")
+	t.Cleanup(func() { authToken, authSkipPaths = oldToken, oldSkip })
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   bool
+	}{
+		{"missing token", "/pglog", "", false},
+		{"wrong token", "/pglog", "Bearer wrong", false},
+		{"token prefix", "/pglog", "Bearer s3c", false},
+		{"token suffix", "/pglog", "Bearer s3cret2", false},
+		{"not bearer", "/pglog", "Basic s3cret", false},
+		{"bare token", "/pglog", "s3cret", false},
+		{"lowercase scheme", "/pglog", "bearer s3cret", false},
+		{"correct token", "/pglog", "Bearer s3cret", true},
+		{"skipped health", "/pglog/health", "", true},
+		{"skipped metrics", "/pglog/metrics", "", true},
+		{"unskipped latest", "/pglog/latest", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if got := authorized(r); got != tt.want {
+			t.Errorf("%s: authorized = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizedWithoutToken(t *testing.T) {
+	oldToken := authToken
+	authToken = ""
+	t.Cleanup(func() { authToken = oldToken })
+
+	if !authorized(httptest.NewRequest(http.MethodPost, "/pglog", nil)) {
+		t.Error("request rejected without AUTH_TOKEN")
+	}
+}
+
+func TestPglogHandlerUnauthorized(t *testing.T) {
+	oldToken := authToken
+	authToken = "s3cret"
+	t.Cleanup(func() { authToken = oldToken })
+
+	rec := httptest.NewRecorder()
+	pglogHandler(rec, httptest.NewRequest(http.MethodPost, "/pglog", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != "Bearer" {
+		t.Errorf("WWW-Authenticate = %q, want Bearer", got)
+	}
+}
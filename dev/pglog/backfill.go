@@ -0,0 +1,317 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Backfill ────────────────────────────────────────────────────────
+// During network partitions the upstream writer buffers values in a cache
+// list per topic, oldest first (RPUSH), in the vtq payload format:
+//
+//	{prefix}:buffer:{topic}  →  {"value": 72.5, "ts": "2026-02-21T15:10:44Z"}
+//
+// POST /pglog/backfill merges the buffers of all configured topics into
+// one timeline ordered by ts and runs change detection between consecutive
+// values, starting from the last logged snapshot. Deadband, counters,
+// edges and min_interval apply (measured on the buffered ts); readings
+// outside bounds are skipped. Each change becomes a row with logged_at set
+// to the buffered ts and the values of all topics as of that moment.
+//
+// The rows are inserted in one transaction, and only once it commits are
+// the consumed entries removed with LTRIM, so a failed backfill can simply
+// be rerun. Entries pushed meanwhile are kept. Entries without a ts are
+// skipped (and trimmed). A backfill holds its line's lock like a logging
+// invocation. Backfilled rows don't move the live last snapshot and
+// aren't published as change notifications.
+
+const (
+	defaultBackfillLimit = 10000
+	maxBackfillLimit     = 100000
+)
+
+// bufferedValue is one parsed entry of a buffer list.
+type bufferedValue struct {
+	Topic string
+	Value string // the compared/stored value, see compareValue
+	TS    time.Time
+}
+
+func backfillHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultBackfillLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxBackfillLimit {
+			writeError(w, codeBadRequest, http.StatusBadRequest,
+				fmt.Errorf("limit must be 1-%d", maxBackfillLimit))
+			return
+		}
+		limit = n
+	}
+
+	status, body := runBackfill(r.Context(), query.Get("config"), limit, query.Get("dry_run") == "true")
+	writeJSON(w, status, body)
+}
+
+// runBackfill reads up to limit entries per topic buffer, logs the
+// changes among them and trims the consumed entries.
+func runBackfill(ctx context.Context, name string, limit int, dryRun bool) (int, interface{}) {
+	s3Ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	config, err := loadConfig(s3Ctx, name)
+	cancel()
+	if err != nil {
+		return configError(err)
+	}
+	if !config.writesPostgres() {
+		return apiError(codeBadRequest, http.StatusBadRequest,
+			fmt.Errorf("backfill needs sink %q or %q", sinkPostgres, sinkBoth))
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+	config, err = expandTopics(cacheCtx, config)
+	if err != nil {
+		return apiError(codeTopicExpand, http.StatusInternalServerError, err)
+	}
+
+	// Held from the buffer read until the consumed entries are trimmed,
+	// so overlapping backfills (and invocations) don't log them twice,
+	// see linelock.go
+	if !dryRun {
+		unlock, _, err := lockLine(ctx, config.lineKey(config.parseTopic(config.Topics[0])))
+		if err != nil {
+			return apiError(codeLineBusy, http.StatusGatewayTimeout, err)
+		}
+		defer unlock()
+	}
+
+	entries, consumed, skipped, err := readBuffers(cacheCtx, config, limit)
+	if err != nil {
+		return apiError(codeCacheRead, http.StatusInternalServerError, err)
+	}
+
+	rows, _ := backfillRows(ctx, config, entries)
+	resp := map[string]interface{}{
+		"table":   config.Table,
+		"entries": len(entries),
+		"rows":    len(rows),
+	}
+	if skipped > 0 {
+		resp["skipped"] = skipped
+	}
+	if dryRun {
+		resp["dry_run"] = true
+		return http.StatusOK, resp
+	}
+
+	if len(rows) > 0 {
+		dbCtx, cancel := context.WithTimeout(ctx, dbTimeout)
+		err = dbWrite(func() error { return ensureTable(dbCtx, config) })
+		if err == nil {
+			err = dbWrite(func() error { return insertBackfill(dbCtx, config, rows) })
+		}
+		cancel()
+		if err != nil {
+			metricInsertErrors.Inc()
+			return apiError(codeInsert, http.StatusInternalServerError, err)
+		}
+		countInserted(ctx, len(rows))
+	}
+
+	// The rows are committed; a failed trim only means the entries are
+	// backfilled again next time.
+	trimmed := 0
+	for topic, n := range consumed {
+		if err := cache.LTrim(cacheCtx, config.cacheKey("buffer", topic), int64(n), -1).Err(); err != nil {
+			logger.WarnContext(ctx, "Failed to trim buffer", "topic", topic, "error", err)
+			continue
+		}
+		trimmed += n
+	}
+	resp["trimmed"] = trimmed
+
+	logger.InfoContext(ctx, "Backfill complete", "table", config.Table, "entries", len(entries), "rows", len(rows))
+	return http.StatusOK, resp
+}
+
+// readBuffers returns the buffered values of all topics ordered by ts,
+// the number of entries read per topic and the number skipped.
+func readBuffers(ctx context.Context, config *pglogConfig, limit int) ([]bufferedValue, map[string]int, int, error) {
+	var entries []bufferedValue
+	consumed := make(map[string]int)
+	skipped := 0
+
+	for _, topic := range config.Topics {
+		raw, err := cache.LRange(ctx, config.cacheKey("buffer", topic), 0, int64(limit-1)).Result()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		consumed[topic] = len(raw)
+
+		for _, entry := range raw {
+			reading, ok := parseVTQ(config.decodeValue(topic, entry))
+			ts, hasTS := reading.timestamp()
+			if !ok || !hasTS {
+				logger.WarnContext(ctx, "Skipping buffered value without value/ts", "topic", topic, "entry", entry)
+				skipped++
+				continue
+			}
+			tag := config.parseTopic(topic).Tag
+			value := config.roundValue(tag, config.transformValue(tag, string(reading.Value)))
+			entries = append(entries, bufferedValue{Topic: topic, Value: value, TS: ts})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].TS.Before(entries[j].TS)
+	})
+	return entries, consumed, skipped, nil
+}
+
+// backfillRows replays the buffered values in order and returns a row for
+// each change, mirroring detectChanges, and the state after the last one.
+func backfillRows(ctx context.Context, config *pglogConfig, entries []bufferedValue) ([]logRow, map[string]*topicSnapshot) {
+	// Each topic starts from the last logged value
+	state := make(map[string]*topicSnapshot, len(config.Topics))
+	lastSnapshotMu.Lock()
+	loadLastSnapshot(ctx, config)
+	for _, topic := range config.Topics {
+		state[topic] = &topicSnapshot{Current: config.compareValue(lastSnapshot[config.stateKey(topic)])}
+	}
+	lastSnapshotMu.Unlock()
+
+	loggedAt := make(map[string]time.Time)
+	var rows []logRow
+
+	for _, entry := range entries {
+		tag := config.parseTopic(entry.Topic).Tag
+		snap := state[entry.Topic]
+		last, exists := snap.Current, snap.Current != ""
+
+		if bounds, ok := config.Bounds[tag]; ok {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(entry.Value), 64); err == nil && !bounds.contains(v) {
+				logger.WarnContext(ctx, "Buffered reading out of range", "topic", entry.Topic, "value", v)
+				continue
+			}
+		}
+
+		// Non-triggering topics, and first values without log_initial,
+		// only update the snapshot
+		if !config.triggers(entry.Topic) || (!exists && !config.logsInitial()) {
+			snap.Current = entry.Value
+			continue
+		}
+
+		change := ""
+		if mode, ok := config.Edge[tag]; ok && exists {
+			if edge, ok := detectEdge(mode, last, entry.Value); ok {
+				if edge == "" {
+					// Edge tags track every transition, including ignored ones
+					snap.Current = entry.Value
+					continue
+				}
+				change = tag + ":" + edge
+			}
+		}
+		if change == "" {
+			if exists && !valueChanged(last, entry.Value, config.deadbandFor(tag), config.Counters[tag].Width) {
+				continue
+			}
+			if interval := config.minIntervalFor(tag); exists && interval > 0 {
+				if prev, ok := loggedAt[entry.Topic]; ok && entry.TS.Sub(prev) < interval {
+					continue
+				}
+			}
+			change = tag
+		}
+
+		prev := buildValuesJSON(config, state)
+		snap.Current = entry.Value
+		values := buildValuesJSON(config, state)
+		loggedAt[entry.Topic] = entry.TS
+
+		row := logRow{
+			Config:     config,
+			UNS:        config.parseTopic(config.Topics[0]),
+			Tag:        tag,
+			Values:     values,
+			Changed:    []string{change},
+			PrevValues: prev,
+			LoggedAt:   entry.TS,
+			Unit:       config.parseTopic(entry.Topic).Unit,
+		}
+		row.Deltas = computeDeltas(config, prev, values, row.Changed)
+		if config.ValueSchema == valueSchemaVTQ {
+			row.SourceTS = entry.TS
+		}
+		rows = append(rows, row)
+	}
+
+	return scopeValues(rows), state
+}
+
+// insertBackfill inserts the rows in one transaction per database (see
+// mirrors.go), creating the monthly partitions their timestamps fall in
+// first.
+func insertBackfill(ctx context.Context, config *pglogConfig, rows []logRow) error {
+	return writeQuorum(ctx, config, func(target *pglogConfig) error {
+		return insertBackfillTx(ctx, target, rows)
+	})
+}
+
+func insertBackfillTx(ctx context.Context, config *pglogConfig, rows []logRow) error {
+	pool, err := dbFor(config)
+	if err != nil {
+		return err
+	}
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if config.Partition == partitionMonthly {
+		months := make(map[string]bool)
+		for _, row := range rows {
+			month := row.LoggedAt.UTC().Format("200601")
+			if !months[month] {
+				months[month] = true
+				if _, err := tx.Exec(ctx, partitionDDL(config.Table, row.LoggedAt)); err != nil {
+					return fmt.Errorf("failed to create partition: %w", err)
+				}
+			}
+		}
+	}
+
+	for _, row := range rows {
+		query, args, err := buildInsert(row)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+
+		query, args, ok, err := buildUpsertCurrent(row)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if _, err := tx.Exec(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to update current state: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
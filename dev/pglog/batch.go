@@ -0,0 +1,273 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ── Batched Inserts ─────────────────────────────────────────────────
+// With ?batch=N the handler queues its row instead of inserting it, and
+// the queue is flushed with a single pgx.Batch (one transaction) once it
+// holds N rows or the oldest row has waited BATCH_MAX_WAIT_MS (default
+// 5000). If the batch fails, rows are retried one by one so a bad row
+// only fails itself; results are reported per row. Batches go through the
+// circuit breaker and insert retries like single rows.
+//
+// Queued rows are held in memory until flushed. The last snapshot moves
+// on when a row is queued, so a row that fails without a dead letter
+// marks its changed tags to be logged again by the next invocation.
+
+type logRow struct {
+	Config  *pglogConfig
+	UNS     unsFields
+	Tag     string
+	Values  map[string]interface{}
+	Changed []string
+
+	// What the values were compared against, see deltas.go
+	PrevValues map[string]interface{}
+	Deltas     map[string]float64
+
+	// Set for backfilled rows (zero = NOW()), see backfill.go
+	LoggedAt time.Time
+
+	// Set when value_schema is "vtq"
+	Quality  map[string]string
+	SourceTS time.Time
+
+	// The unit of Tag, see units.go
+	Unit string
+
+	// Set when a mirrored write reached too few databases: the targets
+	// still to write and how many already hold the row, see mirrors.go
+	Pending []string
+	Written int
+
+	// Set for rows replayed from a dead letter, which publish no change
+	// notification, see deadletter.go
+	Replayed bool
+}
+
+type rowResult struct {
+	Table      string   `json:"table"`
+	Tag        string   `json:"tag"`
+	Changed    []string `json:"changed"`
+	Error      string   `json:"error,omitempty"`
+	DeadLetter string   `json:"deadletter,omitempty"`
+}
+
+var (
+	batchMu      sync.Mutex
+	batchQueue   []logRow
+	batchTimer   *time.Timer
+	batchMaxWait = time.Duration(envIntOrDefault("BATCH_MAX_WAIT_MS", 5000)) * time.Millisecond
+
+	relogTopics = make(map[string]bool) // stateKey → its logged change was dropped, guarded by lastSnapshotMu
+)
+
+// enqueueRow adds a row to the batch queue and flushes it when it holds
+// batchSize rows. Returns the flush results and whether a flush ran.
+func enqueueRow(ctx context.Context, row logRow, batchSize int) ([]rowResult, bool) {
+	batchMu.Lock()
+	batchQueue = append(batchQueue, row)
+	if len(batchQueue) < batchSize {
+		if batchTimer == nil {
+			batchTimer = time.AfterFunc(batchMaxWait, func() {
+				flushCtx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+				defer cancel()
+				flushBatch(flushCtx)
+			})
+		}
+		batchMu.Unlock()
+		return nil, false
+	}
+	batchMu.Unlock()
+
+	return flushBatch(ctx), true
+}
+
+// flushBatch inserts every queued row and empties the queue.
+func flushBatch(ctx context.Context) []rowResult {
+	batchMu.Lock()
+	rows := batchQueue
+	batchQueue = nil
+	if batchTimer != nil {
+		batchTimer.Stop()
+		batchTimer = nil
+	}
+	batchMu.Unlock()
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	results := insertRows(ctx, rows)
+	for i, res := range results {
+		if res.Error != "" {
+			metricInsertErrors.Inc()
+			logger.WarnContext(ctx, "Batched row failed", "table", res.Table, "tag", res.Tag, "error", res.Error)
+			if deadLetterPrefix != "" {
+				key, err := writeDeadLetter(ctx, rows[i], errors.New(res.Error))
+				if err != nil {
+					logger.ErrorContext(ctx, "Failed to write dead letter", "error", err)
+				}
+				results[i].DeadLetter = key
+			}
+			if results[i].DeadLetter == "" {
+				relogChanges(rows[i])
+			}
+		} else {
+			countInserted(ctx, 1)
+		}
+	}
+	return results
+}
+
+// insertRows writes rows in one pgx.Batch, falling back to row-by-row
+// inserts when the batch fails so each failure is isolated.
+func insertRows(ctx context.Context, rows []logRow) []rowResult {
+	// A batch goes to one database, see namespace.go
+	for i := 1; i < len(rows); i++ {
+		if rows[i].Config.Database != rows[0].Config.Database {
+			return append(insertRows(ctx, rows[:i]), insertRows(ctx, rows[i:])...)
+		}
+	}
+
+	results := make([]rowResult, len(rows))
+	type statement struct {
+		query string
+		args  []interface{}
+	}
+	var statements []statement
+	buildErr := false
+
+	for i := range rows {
+		stampLoggedAt(&rows[i])
+		row := rows[i]
+		results[i] = rowResult{Table: row.Config.Table, Tag: row.Tag, Changed: row.Changed}
+		query, args, err := buildInsert(row)
+		if err != nil {
+			results[i].Error = err.Error()
+			buildErr = true
+			continue
+		}
+		statements = append(statements, statement{query, args})
+
+		query, args, ok, err := buildUpsertCurrent(row)
+		if err != nil {
+			results[i].Error = err.Error()
+			buildErr = true
+			continue
+		}
+		if ok {
+			statements = append(statements, statement{query, args})
+		}
+	}
+
+	if !buildErr {
+		// A batch is sent once per mirrored database, see mirrors.go
+		err := dbWrite(func() error {
+			return writeQuorum(ctx, rows[0].Config, func(target *pglogConfig) error {
+				pool, err := dbFor(target)
+				if err != nil {
+					return err
+				}
+				return withInsertRetry(ctx, func() error {
+					batch := &pgx.Batch{}
+					for _, s := range statements {
+						batch.Queue(s.query, s.args...)
+					}
+					return pool.SendBatch(ctx, batch).Close()
+				})
+			})
+		})
+		if err == nil {
+			for _, row := range rows {
+				logInserted(ctx, row)
+				publishChange(row)
+				publishKafka(row)
+				publishWebhook(row)
+				bufferLake(row)
+			}
+			return results
+		}
+		logger.WarnContext(ctx, "Batch insert failed, retrying individually", "rows", len(rows), "error", err)
+
+		// Databases that took the batch already hold every row
+		for i := range rows {
+			markPartialWrite(&rows[i], err)
+		}
+	}
+
+	for i := range rows {
+		if results[i].Error != "" {
+			continue
+		}
+		err := dbWrite(func() error {
+			return insertRow(ctx, &rows[i])
+		})
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return results
+}
+
+// relogChanges makes the changed tags of a dropped row count as changed
+// on the next invocation, as the last snapshot already moved past them.
+func relogChanges(row logRow) {
+	tags := make(map[string]bool, len(row.Changed))
+	for _, entry := range row.Changed {
+		tags[tagOfChange(entry)] = true
+	}
+
+	lastSnapshotMu.Lock()
+	defer lastSnapshotMu.Unlock()
+	for _, topic := range row.Config.Topics {
+		if tags[row.Config.parseTopic(topic).Tag] {
+			relogTopics[row.Config.stateKey(topic)] = true
+		}
+	}
+}
+
+func batchResponse(config *pglogConfig, changed []string, results []rowResult, flushed bool, extra map[string]interface{}) (int, interface{}) {
+	if !flushed {
+		batchMu.Lock()
+		pending := len(batchQueue)
+		batchMu.Unlock()
+
+		return http.StatusAccepted, withExtra(map[string]interface{}{
+			"logged":  false,
+			"queued":  true,
+			"pending": pending,
+			"table":   config.Table,
+			"changed": changed,
+		}, extra)
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+		}
+	}
+
+	status := http.StatusOK
+	if failed == len(results) {
+		status = http.StatusInternalServerError
+	}
+
+	return status, withExtra(map[string]interface{}{
+		"logged":   failed < len(results),
+		"table":    config.Table,
+		"changed":  changed,
+		"inserted": len(results) - failed,
+		"failed":   failed,
+		"rows":     results,
+	}, extra)
+}
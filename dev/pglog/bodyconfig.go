@@ -0,0 +1,90 @@
+package function
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ── Request Body Config ─────────────────────────────────────────────
+// With ALLOW_BODY_CONFIG=true a logging request may carry a config as its
+// JSON body, which is used instead of the S3 config:
+//
+//	curl -X POST -H "Authorization: Bearer $AUTH_TOKEN" \
+//	  -d '{"table": "adhoc_log", "topics": ["v1.0/acme/factory1/mixing/line1/temperature"]}' \
+//	  http://localhost:8080/pglog-line1
+//
+// The body goes through the same defaults and validation as an S3 config.
+// As it lets callers choose the target table, it needs AUTH_TOKEN (the
+// function refuses to start without it) and is rejected on paths listed
+// in AUTH_SKIP_PATHS. A body config can't set "database", so requests
+// can't make the function connect elsewhere, nor "cache_key_prefix" or
+// schemas given as S3 keys, so they can't read other namespaces or
+// arbitrary S3_BUCKET objects. Without the flag the body is ignored, as
+// before.
+
+const maxBodyConfigBytes = 1 << 20
+
+var (
+	allowBodyConfig bool
+
+	errBodyConfigForbidden = errors.New("a body config needs an authenticated path")
+)
+
+func initBodyConfig() {
+	allowBodyConfig = envOrDefault("ALLOW_BODY_CONFIG", "") == "true"
+	if !allowBodyConfig {
+		return
+	}
+	if authToken == "" {
+		fatal("ALLOW_BODY_CONFIG needs AUTH_TOKEN")
+	}
+	logger.Warn("Configs from request bodies enabled")
+}
+
+// bodyConfig returns the config sent as the request body, nil when body
+// configs are disabled or the body is empty.
+func bodyConfig(r *http.Request) (*pglogConfig, error) {
+	if !allowBodyConfig || r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyConfigBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read body: %v", errInvalidConfig, err)
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, nil
+	}
+	if authSkipPaths[path.Base(r.URL.Path)] {
+		return nil, errBodyConfigForbidden
+	}
+
+	// Parse on its own first: parseConfigs would accept an array and open
+	// the pool of a "database"
+	var probe pglogConfig
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, fmt.Errorf("%w: body must be a config object: %v", errInvalidConfig, err)
+	}
+	if probe.Database != "" {
+		return nil, fmt.Errorf("%w: a body config can't set database", errInvalidConfig)
+	}
+	if probe.CacheKeyPrefix != "" {
+		return nil, fmt.Errorf("%w: a body config can't set cache_key_prefix", errInvalidConfig)
+	}
+	for tag, raw := range probe.Schemas {
+		var key string
+		if json.Unmarshal(raw, &key) == nil {
+			return nil, fmt.Errorf("%w: a body config can't load schemas.%s from S3, inline it", errInvalidConfig, tag)
+		}
+	}
+
+	configs, err := parseConfigs(body)
+	if err != nil {
+		return nil, err
+	}
+	return configs[0], nil
+}
@@ -0,0 +1,69 @@
+package function
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyConfig(t *testing.T) {
+	oldAllow, oldSkip := allowBodyConfig, authSkipPaths
+	allowBodyConfig, authSkipPaths = true, parseAuthSkipPaths("health")
+	t.Cleanup(func() { allowBodyConfig, authSkipPaths = oldAllow, oldSkip })
+
+	const topics = `"topics": ["v1.0/acme/factory1/mixing/line1/temperature"]`
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		want    string // table of the returned config, "" for none
+		wantErr error
+	}{
+		{"empty body", "/pglog", "", "", nil},
+		{"blank body", "/pglog", " \n", "", nil},
+		{"config", "/pglog", `{"table": "adhoc_log", ` + topics + `}`, "adhoc_log", nil},
+		{"default table", "/pglog", `{` + topics + `}`, "uns_log", nil},
+		{"inline schema", "/pglog", `{` + topics + `, "schemas": {"temperature": {"type": "number"}}}`, "uns_log", nil},
+		{"skipped path", "/pglog/health", `{` + topics + `}`, "", errBodyConfigForbidden},
+		{"array", "/pglog", `[{"name": "a", ` + topics + `}]`, "", errInvalidConfig},
+		{"database", "/pglog", `{"database": "postgres://evil/db", ` + topics + `}`, "", errInvalidConfig},
+		{"cache_key_prefix", "/pglog", `{"cache_key_prefix": "other", ` + topics + `}`, "", errInvalidConfig},
+		{"schema from S3", "/pglog", `{` + topics + `, "schemas": {"temperature": "schemas/temperature.json"}}`, "", errInvalidConfig},
+		{"invalid table", "/pglog", `{"table": "adhoc-log", ` + topics + `}`, "", errInvalidConfig},
+		{"not JSON", "/pglog", `table=adhoc_log`, "", errInvalidConfig},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		config, err := bodyConfig(r)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		table := ""
+		if config != nil {
+			table = config.Table
+		}
+		if table != tt.want {
+			t.Errorf("%s: table %q, want %q", tt.name, table, tt.want)
+		}
+	}
+}
+
+func TestBodyConfigDisabled(t *testing.T) {
+	oldAllow := allowBodyConfig
+	allowBodyConfig = false
+	t.Cleanup(func() { allowBodyConfig = oldAllow })
+
+	r := httptest.NewRequest(http.MethodPost, "/pglog", strings.NewReader(`{"database": "postgres://evil/db"}`))
+	if config, err := bodyConfig(r); config != nil || err != nil {
+		t.Errorf("bodyConfig = %v, %v, want the body ignored", config, err)
+	}
+}
@@ -0,0 +1,95 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ── Bounds ──────────────────────────────────────────────────────────
+// Per-tag plausibility limits keep sensor glitches (e.g. -9999) out of
+// the log:
+//
+//	"bounds": { "temperature": { "min": -40, "max": 150 } },
+//	"out_of_range": "null"
+//
+// A numeric reading outside its range is excluded from change detection
+// and stored as NULL ("null", the default) or as the last good value
+// ("last_good"). Affected tags are listed as out_of_range in the response.
+
+const (
+	outOfRangeNull     = "null"
+	outOfRangeLastGood = "last_good"
+)
+
+type tagBounds struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// contains reports whether v is within the bounds (inclusive).
+func (b tagBounds) contains(v float64) bool {
+	return (b.Min == nil || v >= *b.Min) && (b.Max == nil || v <= *b.Max)
+}
+
+func validateBounds(config *pglogConfig) error {
+	switch config.OutOfRange {
+	case outOfRangeNull, outOfRangeLastGood:
+	default:
+		return fmt.Errorf("out_of_range must be %q or %q", outOfRangeNull, outOfRangeLastGood)
+	}
+	for tag, b := range config.Bounds {
+		if b.Min != nil && b.Max != nil && *b.Min > *b.Max {
+			return fmt.Errorf("bounds.%s: min is greater than max", tag)
+		}
+	}
+	return nil
+}
+
+// applyBounds replaces out-of-range readings in the snapshot before change
+// detection: with an empty value (NULL, never a change) or with the last
+// good value (unchanged by definition). It returns the affected tags.
+func applyBounds(ctx context.Context, config *pglogConfig, snapshot map[string]*topicSnapshot) []string {
+	if len(config.Bounds) == 0 {
+		return nil
+	}
+
+	lastSnapshotMu.Lock()
+	defer lastSnapshotMu.Unlock()
+
+	usePrev := config.ChangeSource == changeSourcePrev
+	if !usePrev && config.OutOfRange == outOfRangeLastGood {
+		loadLastSnapshot(ctx, config)
+	}
+
+	var outOfRange []string
+	for _, topic := range config.Topics {
+		tag := config.parseTopic(topic).Tag
+		bounds, ok := config.Bounds[tag]
+		snap := snapshot[topic]
+		if !ok || snap == nil || snap.Current == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(config.compareValue(snap.Current)), 64)
+		if err != nil || bounds.contains(v) {
+			continue
+		}
+
+		logger.WarnContext(ctx, "Reading out of range", "topic", topic, "value", v)
+		outOfRange = append(outOfRange, tag)
+		snap.Rejected, snap.Reason = snap.Current, reasonOutOfRange
+
+		switch {
+		case config.OutOfRange != outOfRangeLastGood:
+			snap.Current = ""
+		case usePrev:
+			snap.Current = snap.Previous
+		default:
+			snap.Current = lastSnapshot[config.stateKey(topic)]
+		}
+	}
+
+	return outOfRange
+}
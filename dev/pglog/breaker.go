@@ -0,0 +1,116 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ── Circuit Breaker ─────────────────────────────────────────────────
+// Guards the Postgres write path. After DB_BREAKER_THRESHOLD consecutive
+// connection failures or timeouts the circuit opens and writes fail fast
+// with 503 instead of each waiting for DB_TIMEOUT_MS. After
+// DB_BREAKER_COOLDOWN_MS it half-opens and lets a single request through:
+// success closes the circuit, failure opens it for another cooldown.
+// A threshold of 0 disables the breaker.
+
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool // a half-open trial request is in flight
+}
+
+var dbBreaker = &circuitBreaker{
+	threshold: envIntOrDefault("DB_BREAKER_THRESHOLD", 5),
+	cooldown:  time.Duration(envIntOrDefault("DB_BREAKER_COOLDOWN_MS", 30000)) * time.Millisecond,
+	state:     circuitClosed,
+}
+
+// allow reports whether a call may proceed, moving an open circuit to
+// half-open once the cooldown has passed.
+func (b *circuitBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return errCircuitOpen
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return nil
+	case circuitHalfOpen:
+		if b.probing {
+			return errCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call. Only
+// connection failures and timeouts count; server errors (e.g. a
+// constraint violation) mean the database is up.
+func (b *circuitBreaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || !(isConnectionError(err) || errors.Is(err, context.DeadlineExceeded)) {
+		if b.state != circuitClosed {
+			logger.Info("Circuit breaker closed")
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state != circuitOpen {
+			logger.Warn("Circuit breaker opened", "failures", b.failures, "cooldown", b.cooldown.String())
+		}
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) currentState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// dbWrite runs a Postgres write through the breaker. Writes reconnect
+// per database in writeQuorum, so a retry never repeats a write another
+// database already took.
+func dbWrite(fn func() error) error {
+	if err := dbBreaker.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	dbBreaker.record(err)
+	return err
+}
@@ -0,0 +1,179 @@
+package function
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ── Cache Client ────────────────────────────────────────────────────
+// CACHE_URL selects the client type by scheme:
+//   redis://host:6379                      → single node
+//   redis+cluster://host1:6379,host2:6379  → cluster (or CACHE_CLUSTER=true)
+//   redis+sentinel://s1:26379,s2:26379     → sentinel failover, master
+//                                            name from CACHE_SENTINEL_MASTER
+//
+// All three satisfy redis.UniversalClient, so the rest of the function is
+// unaware of the deployment mode.
+//
+// Credentials and TLS can also be given separately, overriding the URL:
+//   CACHE_USERNAME, CACHE_PASSWORD  → ACL user / password
+//   CACHE_TLS_CA_FILE               → PEM CA bundle; enables TLS
+//   CACHE_TLS_SKIP_VERIFY=true      → enables TLS without verifying the
+//                                     server certificate (testing only)
+
+func newCacheClient(cacheURL string) (redis.UniversalClient, error) {
+	auth, err := loadCacheAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(cacheURL, "redis+cluster://"):
+		addrs, username, password, err := parseMultiHostURL(cacheURL)
+		if err != nil {
+			return nil, err
+		}
+		opts := &redis.ClusterOptions{
+			Addrs:    addrs,
+			Username: username,
+			Password: password,
+		}
+		auth.apply(&opts.Username, &opts.Password, &opts.TLSConfig)
+		logger.Info("Cache mode: cluster", "seed_nodes", len(addrs), "auth", authMode(opts.Username, opts.Password), "tls", opts.TLSConfig != nil)
+		return redis.NewClusterClient(opts), nil
+
+	case strings.HasPrefix(cacheURL, "redis+sentinel://"):
+		addrs, username, password, err := parseMultiHostURL(cacheURL)
+		if err != nil {
+			return nil, err
+		}
+		master := envOrDefault("CACHE_SENTINEL_MASTER", "")
+		if master == "" {
+			return nil, fmt.Errorf("CACHE_SENTINEL_MASTER is required for redis+sentinel://")
+		}
+		opts := &redis.FailoverOptions{
+			MasterName:    master,
+			SentinelAddrs: addrs,
+			Username:      username,
+			Password:      password,
+		}
+		auth.apply(&opts.Username, &opts.Password, &opts.TLSConfig)
+		logger.Info("Cache mode: sentinel", "master", master, "sentinels", len(addrs), "auth", authMode(opts.Username, opts.Password), "tls", opts.TLSConfig != nil)
+		return redis.NewFailoverClient(opts), nil
+	}
+
+	opts, err := redis.ParseURL(cacheURL)
+	if err != nil {
+		return nil, err
+	}
+	auth.apply(&opts.Username, &opts.Password, &opts.TLSConfig)
+
+	if envOrDefault("CACHE_CLUSTER", "") == "true" {
+		logger.Info("Cache mode: cluster", "seed", opts.Addr, "auth", authMode(opts.Username, opts.Password), "tls", opts.TLSConfig != nil)
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     []string{opts.Addr},
+			Username:  opts.Username,
+			Password:  opts.Password,
+			TLSConfig: opts.TLSConfig,
+		}), nil
+	}
+
+	logger.Info("Cache mode: single", "addr", opts.Addr, "auth", authMode(opts.Username, opts.Password), "tls", opts.TLSConfig != nil)
+	return redis.NewClient(opts), nil
+}
+
+// cacheAuth holds the CACHE_USERNAME/PASSWORD/TLS_* overrides.
+type cacheAuth struct {
+	username   string
+	password   string
+	caPool     *x509.CertPool
+	skipVerify bool
+}
+
+func loadCacheAuth() (cacheAuth, error) {
+	auth := cacheAuth{
+		username:   envOrDefault("CACHE_USERNAME", ""),
+		password:   envOrDefault("CACHE_PASSWORD", ""),
+		skipVerify: envOrDefault("CACHE_TLS_SKIP_VERIFY", "") == "true",
+	}
+
+	if caFile := envOrDefault("CACHE_TLS_CA_FILE", ""); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return cacheAuth{}, fmt.Errorf("failed to read CACHE_TLS_CA_FILE: %w", err)
+		}
+		auth.caPool = x509.NewCertPool()
+		if !auth.caPool.AppendCertsFromPEM(pem) {
+			return cacheAuth{}, fmt.Errorf("no certificates found in CACHE_TLS_CA_FILE %s", caFile)
+		}
+	}
+	if auth.skipVerify {
+		logger.Warn("CACHE_TLS_SKIP_VERIFY is set, the cache server certificate is not verified")
+	}
+	return auth, nil
+}
+
+// apply overrides the credentials and TLS settings parsed from CACHE_URL.
+func (a cacheAuth) apply(username, password *string, tlsConfig **tls.Config) {
+	if a.username != "" {
+		*username = a.username
+	}
+	if a.password != "" {
+		*password = a.password
+	}
+	if a.caPool == nil && !a.skipVerify {
+		return
+	}
+
+	// Keep what rediss:// set up (e.g. ServerName)
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if *tlsConfig != nil {
+		cfg = (*tlsConfig).Clone()
+	}
+	if a.caPool != nil {
+		cfg.RootCAs = a.caPool
+	}
+	cfg.InsecureSkipVerify = a.skipVerify
+	*tlsConfig = cfg
+}
+
+// authMode describes the credentials in use without revealing them.
+func authMode(username, password string) string {
+	switch {
+	case username != "":
+		return "acl"
+	case password != "":
+		return "password"
+	default:
+		return "none"
+	}
+}
+
+// parseMultiHostURL splits scheme://[user:pass@]host1:port,host2:port into
+// its address list and credentials.
+func parseMultiHostURL(raw string) ([]string, string, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	var addrs []string
+	for _, addr := range strings.Split(u.Host, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, "", "", fmt.Errorf("no hosts in %s", u.Scheme+"://")
+	}
+
+	username := u.User.Username()
+	password, _ := u.User.Password()
+	return addrs, username, password, nil
+}
@@ -0,0 +1,141 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ── Hash Cache Layout ───────────────────────────────────────────────
+// By default every topic is its own string key ({prefix}:data:{topic}).
+// Writers that store a whole line in one hash select:
+//
+//	"cache_layout": "hash",
+//	"hash_key": "line:{line}"
+//
+// hash_key is relative to the key prefix (so "uns:line:line1" with the
+// default prefix) and may use any UNS level as a {placeholder}. Fields
+// are tag names. Topics sharing a hash are read with a single HMGET.
+//
+// Hashes carry no previous value, so change_source "prev" isn't
+// available, and wildcard topics can't be expanded.
+
+const cacheLayoutHash = "hash"
+
+func validateCacheLayout(config *pglogConfig) error {
+	var name, key string
+	switch config.CacheLayout {
+	case "":
+		if config.HashKey != "" {
+			return fmt.Errorf("hash_key requires cache_layout %q", cacheLayoutHash)
+		}
+		if config.StreamKey != "" {
+			return fmt.Errorf("stream_key requires cache_layout %q", cacheLayoutStream)
+		}
+		if config.MapKey != "" {
+			return fmt.Errorf("map_key requires cache_layout %q", cacheLayoutJSONMap)
+		}
+		return nil
+	case cacheLayoutHash:
+		name, key = "hash_key", config.HashKey
+	case cacheLayoutStream:
+		name, key = "stream_key", config.StreamKey
+	case cacheLayoutJSONMap:
+		name, key = "map_key", config.MapKey
+		if config.ValueEncoding != valueEncodingJSON {
+			return fmt.Errorf("cache_layout %q needs value_encoding %q", cacheLayoutJSONMap, valueEncodingJSON)
+		}
+	default:
+		return fmt.Errorf("cache_layout must be empty, %q, %q or %q", cacheLayoutHash, cacheLayoutStream, cacheLayoutJSONMap)
+	}
+
+	if key == "" {
+		return fmt.Errorf("cache_layout %q requires %s", config.CacheLayout, name)
+	}
+	if strings.ContainsAny(levelPlaceholder.ReplaceAllString(key, ""), "*?[]\\{}") {
+		return fmt.Errorf("%s %q contains a reserved character", name, key)
+	}
+
+	levels := make(map[string]bool)
+	for _, level := range config.levelColumns() {
+		levels[level] = true
+	}
+	for _, m := range levelPlaceholder.FindAllStringSubmatch(key, -1) {
+		if !levels[m[1]] {
+			return fmt.Errorf("%s: unknown UNS level {%s}", name, m[1])
+		}
+	}
+
+	if config.ChangeSource == changeSourcePrev {
+		return fmt.Errorf("change_source %q is not available with cache_layout %q", changeSourcePrev, config.CacheLayout)
+	}
+	for _, topic := range config.Topics {
+		if isWildcardTopic(topic) {
+			return fmt.Errorf("topic %q: wildcards are not available with cache_layout %q", topic, config.CacheLayout)
+		}
+	}
+	return nil
+}
+
+// hashKey returns the cache hash holding a topic's value.
+func (c *pglogConfig) hashKey(uns unsFields) string {
+	return c.cachePrefix() + ":" + expandLevels(c.HashKey, uns)
+}
+
+// readTopicsFromHash reads topic values from hash fields, one HMGET per
+// hash. Previous is always empty.
+func readTopicsFromHash(ctx context.Context, config *pglogConfig) (map[string]*topicSnapshot, error) {
+	type hashRead struct {
+		topics []string
+		fields []string
+	}
+
+	var keys []string
+	reads := make(map[string]*hashRead)
+	for _, topic := range config.Topics {
+		uns := config.parseTopic(topic)
+		key := config.hashKey(uns)
+		read, ok := reads[key]
+		if !ok {
+			read = &hashRead{}
+			reads[key] = read
+			keys = append(keys, key)
+		}
+		read.topics = append(read.topics, topic)
+		read.fields = append(read.fields, uns.Tag)
+	}
+
+	pipe := cache.Pipeline()
+	for _, key := range keys {
+		pipe.HMGet(ctx, key, reads[key].fields...)
+	}
+
+	results, err := pipe.Exec(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err := pipelineError(results, err); err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]*topicSnapshot, len(config.Topics))
+	for i, key := range keys {
+		var values []interface{}
+		if i < len(results) {
+			values, _ = results[i].(*redis.SliceCmd).Result()
+		}
+		for j, topic := range reads[key].topics {
+			current := ""
+			if j < len(values) {
+				if s, ok := values[j].(string); ok {
+					current = s
+				}
+			}
+			snapshot[topic] = &topicSnapshot{Current: current}
+		}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,86 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// ── CloudEvent Trigger ──────────────────────────────────────────────
+// With TRIGGER_TYPE=cloudevent the function is registered as a CloudEvent
+// function (Pub/Sub or Eventarc push) instead of an HTTP one. Each event
+// runs the same pipeline as POST /pglog.
+//
+// Event attribute contract — looked up first as CloudEvent extension
+// attributes, then as Pub/Sub message attributes (data.message.attributes):
+//
+//	config          config name, as ?config= (default FUNCTION_TARGET)
+//	dryrun          "true" for a dry run, as ?dry_run=true
+//	eventtime       row timestamp, as the X-Event-Time header
+//	idempotencykey  as the Idempotency-Key header (default: the event ID)
+//	traceparent     W3C trace context, for metric exemplars (see trace.go)
+//
+// The event data is otherwise ignored. Failures that may succeed on a
+// retry (5xx, 429) are returned as errors so the event is redelivered; config
+// and other 4xx errors are logged and acknowledged.
+
+const triggerCloudEvent = "cloudevent"
+
+// pubSubPush is the data of a Pub/Sub message delivered as a CloudEvent.
+type pubSubPush struct {
+	Message struct {
+		Attributes map[string]string `json:"attributes"`
+	} `json:"message"`
+}
+
+func pglogEventHandler(ctx context.Context, e cloudevents.Event) error {
+	ctx = contextWithRequestID(ctx, e.ID())
+	ctx = contextWithTraceID(ctx, parseTraceparent(eventAttribute(e, "traceparent")))
+	opts := logOptions{
+		Config: eventAttribute(e, "config"),
+		DryRun: eventAttribute(e, "dryrun") == "true",
+	}
+
+	if raw := eventAttribute(e, "eventtime"); raw != "" {
+		ts, err := parseEventTime(raw)
+		if err != nil {
+			logger.WarnContext(ctx, "Event not processed", "event", e.ID(), "error", err)
+			return nil
+		}
+		opts.EventTime = ts
+	}
+
+	opts.IdempotencyKey = eventAttribute(e, "idempotencykey")
+	if opts.IdempotencyKey == "" {
+		opts.IdempotencyKey = e.ID()
+	}
+
+	status, body := runLogOnce(ctx, opts)
+	if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		return fmt.Errorf("event %s: status %d: %v", e.ID(), status, body)
+	}
+	if status >= http.StatusBadRequest {
+		logger.WarnContext(ctx, "Event not processed", "event", e.ID(), "status", status, "response", body)
+		return nil
+	}
+
+	logger.DebugContext(ctx, "Processed event", "event", e.ID(), "type", e.Type(), "status", status)
+	return nil
+}
+
+// eventAttribute returns an extension attribute of the event, falling
+// back to the attributes of a wrapped Pub/Sub message.
+func eventAttribute(e cloudevents.Event, name string) string {
+	if v, ok := e.Extensions()[name]; ok {
+		return fmt.Sprint(v)
+	}
+
+	var push pubSubPush
+	if err := json.Unmarshal(e.Data(), &push); err == nil {
+		return push.Message.Attributes[name]
+	}
+	return ""
+}
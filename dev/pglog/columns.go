@@ -0,0 +1,129 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ── Typed Columns ───────────────────────────────────────────────────
+// Tags can additionally be written to typed columns so they can be
+// queried and aggregated without JSONB expressions:
+//
+//	"columns": [
+//	  { "tag": "temperature", "column": "temperature", "type": "double" },
+//	  { "tag": "state", "column": "state", "type": "text" }
+//	]
+//
+// ensureTable adds missing columns (see migrate.go). A value that doesn't convert to the
+// declared type is written as NULL and logged as a warning.
+
+type columnMapping struct {
+	Tag    string `json:"tag"`
+	Column string `json:"column"`
+	Type   string `json:"type"`
+}
+
+// columnTypes maps the config type names to Postgres column types.
+var columnTypes = map[string]string{
+	"double":  "DOUBLE PRECISION",
+	"bigint":  "BIGINT",
+	"boolean": "BOOLEAN",
+	"text":    "TEXT",
+}
+
+// reservedColumns are the built-in columns of the log table, apart from
+// the UNS level columns which depend on uns_schema (see uns.go).
+var reservedColumns = map[string]bool{
+	"id": true, "logged_at": true, "tag": true, "values": true, "changed": true,
+	"tenant": true, "quality": true, "source_ts": true, "prev_values": true, "deltas": true,
+	"unit": true,
+}
+
+func validateColumns(columns []columnMapping, levels []string) error {
+	seen := make(map[string]bool)
+	for _, level := range levels {
+		seen[strings.ToLower(level)] = true
+	}
+	for i, col := range columns {
+		if col.Tag == "" {
+			return fmt.Errorf("columns[%d]: tag is required", i)
+		}
+		if err := validateIdentifier(col.Column); err != nil {
+			return fmt.Errorf("columns[%d]: %v", i, err)
+		}
+		name := strings.ToLower(col.Column)
+		if reservedColumns[name] {
+			return fmt.Errorf("columns[%d]: %q is a reserved column", i, col.Column)
+		}
+		if seen[name] {
+			return fmt.Errorf("columns[%d]: duplicate or UNS level column %q", i, col.Column)
+		}
+		seen[name] = true
+		if _, ok := columnTypes[col.Type]; !ok {
+			return fmt.Errorf("columns[%d]: unsupported type %q (double, bigint, boolean, text)", i, col.Type)
+		}
+	}
+	return nil
+}
+
+// typedColumnValue converts a parsed tag value to the column's Go type,
+// returning nil (NULL) when the value is missing or doesn't convert.
+func typedColumnValue(col columnMapping, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	converted, ok := convertColumnValue(col.Type, value)
+	if !ok {
+		logger.Warn("Value does not match column type, writing NULL",
+			"tag", col.Tag, "value", value, "type", col.Type, "column", col.Column)
+		return nil
+	}
+	return converted
+}
+
+func convertColumnValue(typ string, value interface{}) (interface{}, bool) {
+	switch typ {
+	case "double":
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return f, err == nil
+		}
+
+	case "bigint":
+		switch v := value.(type) {
+		case float64:
+			return int64(v), v == math.Trunc(v)
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return n, err == nil
+		}
+
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			return b, err == nil
+		}
+
+	case "text":
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case float64, bool:
+			return fmt.Sprint(v), true
+		default:
+			raw, err := json.Marshal(v)
+			return string(raw), err == nil
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,45 @@
+package function
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateColumns(t *testing.T) {
+	levels := []string{"enterprise", "site", "area", "line"}
+	tests := []struct {
+		name    string
+		columns []columnMapping
+		wantErr string
+	}{
+		{"none", nil, ""},
+		{"valid", []columnMapping{
+			{Tag: "temperature", Column: "temperature", Type: "double"},
+			{Tag: "count", Column: "count", Type: "bigint"},
+			{Tag: "running", Column: "running", Type: "boolean"},
+			{Tag: "state", Column: "state", Type: "text"},
+		}, ""},
+		{"missing tag", []columnMapping{{Column: "temperature", Type: "double"}}, "tag is required"},
+		{"invalid column", []columnMapping{{Tag: "t", Column: "temp; DROP TABLE x", Type: "double"}}, "identifier"},
+		{"reserved column", []columnMapping{{Tag: "t", Column: "values", Type: "double"}}, "reserved"},
+		{"reserved unit", []columnMapping{{Tag: "t", Column: "Unit", Type: "text"}}, "reserved"},
+		{"level column", []columnMapping{{Tag: "t", Column: "line", Type: "text"}}, "UNS level"},
+		{"duplicate", []columnMapping{
+			{Tag: "a", Column: "temp", Type: "double"},
+			{Tag: "b", Column: "TEMP", Type: "double"},
+		}, "duplicate"},
+		{"unsupported type", []columnMapping{{Tag: "t", Column: "temp", Type: "float"}}, "unsupported type"},
+	}
+	for _, tt := range tests {
+		err := validateColumns(tt.columns, levels)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
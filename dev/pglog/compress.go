@@ -0,0 +1,115 @@
+package function
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ── Response Compression ────────────────────────────────────────────
+// Responses echo the full values snapshot, which for lines with hundreds
+// of tags is tens of KB. When the request sends Accept-Encoding: gzip
+// (or deflate) pglogHandler wraps the ResponseWriter, so everything
+// written through writeJSON is compressed. /metrics is left alone, as
+// promhttp negotiates compression itself.
+
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (c *compressedResponseWriter) Write(b []byte) (int, error) {
+	return c.w.Write(b)
+}
+
+// Flush pushes what was compressed so far to the client, for streamed
+// responses.
+func (c *compressedResponseWriter) Flush() {
+	if f, ok := c.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressedResponseWriter) WriteHeader(status int) {
+	c.Header().Del("Content-Length")
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// compressResponse returns the writer to use for the response and a
+// function that flushes it, which must be called once the response is
+// written.
+func compressResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+	var enc io.WriteCloser
+	switch encoding {
+	case "gzip":
+		enc = gzip.NewWriter(w)
+	case "deflate":
+		enc = zlib.NewWriter(w) // HTTP "deflate" is the zlib format
+	default:
+		return w, func() {}
+	}
+
+	w.Header().Set("Content-Encoding", encoding)
+	return &compressedResponseWriter{ResponseWriter: w, w: enc}, func() {
+		if err := enc.Close(); err != nil {
+			logger.DebugContext(r.Context(), "Failed to finish compressed response", "error", err)
+		}
+	}
+}
+
+// negotiateEncoding picks gzip over deflate from an Accept-Encoding
+// header, skipping codings refused with q=0. Returns "" for neither.
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(coding))] = true
+	}
+
+	switch {
+	case accepted["gzip"] || accepted["*"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// ── Compressed Configs ──────────────────────────────────────────────
+// Config objects for lines with thousands of tags can be stored
+// gzip-compressed, either with Content-Encoding: gzip or under a .gz key
+// (e.g. S3_DEFAULT_CONFIG_KEY=functions/pglog/_default.json.gz). They are
+// decompressed before parsing; plain objects are read as they are.
+
+// readConfigBody returns the config object's JSON, decompressed if needed.
+func readConfigBody(body io.Reader, contentEncoding, key string) ([]byte, error) {
+	if !strings.EqualFold(strings.TrimSpace(contentEncoding), "gzip") && !strings.HasSuffix(key, ".gz") {
+		return io.ReadAll(body)
+	}
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	defer gz.Close()
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	return data, nil
+}
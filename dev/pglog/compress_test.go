@@ -0,0 +1,110 @@
+package function
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"GZIP;q=0.5", "gzip"},
+		{"*", "gzip"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"gzip; q=0", ""},
+		{"br, identity", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	payload := map[string]interface{}{"values": map[string]interface{}{"temperature": 72.5}, "padding": strings.Repeat("x", 1024)}
+
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+		decode         func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"deflate", "deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+		{"", "", func(r io.Reader) (io.Reader, error) { return r, nil }},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/pglog", nil)
+		r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		rec := httptest.NewRecorder()
+
+		w, finish := compressResponse(rec, r)
+		writeJSON(w, http.StatusOK, payload)
+		finish()
+
+		if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("%q: Content-Encoding = %q, want %q", tt.acceptEncoding, got, tt.wantEncoding)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%q: Vary = %q", tt.acceptEncoding, got)
+		}
+		body, err := tt.decode(rec.Body)
+		if err != nil {
+			t.Errorf("%q: %v", tt.acceptEncoding, err)
+			continue
+		}
+		got, err := io.ReadAll(body)
+		if err != nil || !strings.Contains(string(got), "temperature") {
+			t.Errorf("%q: decoded body %q, %v", tt.acceptEncoding, got, err)
+		}
+	}
+}
+
+func TestReadConfigBody(t *testing.T) {
+	compressed, err := os.ReadFile("testdata/config.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := `{"table": "uns_log", "topics": ["v1.0/acme/factory1/mixing/line1/temperature"]}`
+
+	tests := []struct {
+		name            string
+		body            string
+		contentEncoding string
+		key             string
+		wantErr         bool
+	}{
+		{"gzip encoding", string(compressed), "gzip", "functions/pglog/line1.json", false},
+		{"gz key", string(compressed), "", "functions/pglog/line1.json.gz", false},
+		{"encoding case and spaces", string(compressed), " GZIP ", "functions/pglog/line1.json", false},
+		{"plain", plain, "", "functions/pglog/line1.json", false},
+		{"plain under gz key", plain, "", "functions/pglog/line1.json.gz", true},
+		{"truncated", string(compressed[:len(compressed)/2]), "gzip", "functions/pglog/line1.json", true},
+	}
+	for _, tt := range tests {
+		data, err := readConfigBody(strings.NewReader(tt.body), tt.contentEncoding, tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		configs, err := parseConfigs(data)
+		if err != nil || configs[0].Topics[0] != "v1.0/acme/factory1/mixing/line1/temperature" {
+			t.Errorf("%s: parseConfigs = %v, %v", tt.name, configs, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package function
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ── CORS ────────────────────────────────────────────────────────────
+// Browser dashboards calling the read endpoints (/config, /latest,
+// /topics, ...) need CORS headers. ALLOWED_ORIGINS lists the origins
+// allowed to (comma-separated, exact match such as
+// "https://status.example.com", or "*" for any):
+//
+//	ALLOWED_ORIGINS=https://status.example.com,http://localhost:5173
+//
+// Responses to an allowed Origin carry Access-Control-Allow-Origin, and
+// OPTIONS preflights are answered with 204 before authentication, since
+// browsers send them without the Authorization header. Without
+// ALLOWED_ORIGINS no CORS headers are sent, as before.
+
+// corsMaxAge is how long browsers may cache a preflight, in seconds.
+const corsMaxAge = "600"
+
+var allowedOrigins = parseAllowedOrigins(envOrDefault("ALLOWED_ORIGINS", ""))
+
+// corsAllowHeaders are the request headers the function reads.
+var corsAllowHeaders = strings.Join([]string{
+	"Authorization", "Content-Type", "Content-Encoding", "Idempotency-Key",
+	"X-Dry-Run", "X-Event-Time", requestIDHeader, "traceparent",
+}, ", ")
+
+func parseAllowedOrigins(raw string) map[string]bool {
+	origins := make(map[string]bool)
+	for _, origin := range strings.Split(raw, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			origins[origin] = true
+		}
+	}
+	return origins
+}
+
+// handleCORS sets the CORS headers for an allowed origin and reports
+// whether the request was a preflight, which it has then answered.
+func handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	if len(allowedOrigins) == 0 {
+		return false
+	}
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	switch {
+	case origin == "":
+		return false
+	case allowedOrigins["*"]:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	case allowedOrigins[origin]:
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	default:
+		// Not allowed: no headers, and the browser blocks the response
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return preflight
+	}
+	w.Header().Set("Access-Control-Expose-Headers", requestIDHeader+", Retry-After")
+
+	if !preflight {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+	w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
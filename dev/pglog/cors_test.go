@@ -0,0 +1,74 @@
+package function
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleCORS(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowed       string
+		method        string
+		origin        string
+		requestMethod string // Access-Control-Request-Method
+		wantHandled   bool
+		wantOrigin    string
+		wantStatus    int
+	}{
+		{"disabled", "", http.MethodGet, "https://status.example.com", "", false, "", http.StatusOK},
+		{"allowed", "https://status.example.com, http://localhost:5173/", http.MethodGet, "https://status.example.com", "", false, "https://status.example.com", http.StatusOK},
+		{"allowed trailing slash", "http://localhost:5173/", http.MethodGet, "http://localhost:5173", "", false, "http://localhost:5173", http.StatusOK},
+		{"wildcard", "*", http.MethodGet, "https://any.example.com", "", false, "*", http.StatusOK},
+		{"not allowed", "https://status.example.com", http.MethodGet, "https://evil.example.com", "", false, "", http.StatusOK},
+		{"no origin", "https://status.example.com", http.MethodGet, "", "", false, "", http.StatusOK},
+		{"preflight", "https://status.example.com", http.MethodOptions, "https://status.example.com", "POST", true, "https://status.example.com", http.StatusNoContent},
+		{"preflight not allowed", "https://status.example.com", http.MethodOptions, "https://evil.example.com", "POST", true, "", http.StatusNoContent},
+		{"options without request method", "https://status.example.com", http.MethodOptions, "https://status.example.com", "", false, "https://status.example.com", http.StatusOK},
+	}
+
+	old := allowedOrigins
+	t.Cleanup(func() { allowedOrigins = old })
+	for _, tt := range tests {
+		allowedOrigins = parseAllowedOrigins(tt.allowed)
+
+		r := httptest.NewRequest(tt.method, "/pglog/latest", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if tt.requestMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+		}
+		rec := httptest.NewRecorder()
+
+		if got := handleCORS(rec, r); got != tt.wantHandled {
+			t.Errorf("%s: handled = %v, want %v", tt.name, got, tt.wantHandled)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if tt.wantHandled && tt.wantOrigin != "" && rec.Header().Get("Access-Control-Allow-Headers") == "" {
+			t.Errorf("%s: preflight without Access-Control-Allow-Headers", tt.name)
+		}
+	}
+}
+
+// Preflights carry no token, so they must be answered before auth.
+func TestPglogHandlerPreflightWithAuth(t *testing.T) {
+	oldOrigins, oldToken := allowedOrigins, authToken
+	allowedOrigins, authToken = parseAllowedOrigins("https://status.example.com"), "s3cret"
+	t.Cleanup(func() { allowedOrigins, authToken = oldOrigins, oldToken })
+
+	r := httptest.NewRequest(http.MethodOptions, "/pglog/latest", nil)
+	r.Header.Set("Origin", "https://status.example.com")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	pglogHandler(rec, r)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("preflight = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
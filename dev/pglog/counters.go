@@ -0,0 +1,88 @@
+package function
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ── Wrapping Counters ───────────────────────────────────────────────
+// PLC counters and totalizers wrap at their bit width (e.g. 16-bit
+// counters go 65535 → 0). Tags listed under "counters" have their deltas
+// measured across the wrap, so 65535 → 0 is +1 rather than -65535.
+//
+//	"counters": {
+//	  "bottles": { "width": 16 },
+//	  "energy_wh": { "width": 32, "unwrap": true }
+//	}
+//
+// With "unwrap": true the cumulative (never-wrapping) value is tracked in
+// memory and stored in the snapshot as "{tag}_unwrapped". It is seeded
+// from the first value seen after startup, and more than one wrap between
+// two invocations cannot be detected.
+
+type counterConfig struct {
+	Width  uint `json:"width"`
+	Unwrap bool `json:"unwrap,omitempty"`
+}
+
+type counterTotal struct {
+	Raw   float64
+	Total float64
+}
+
+var (
+	counterTotalsByTopic = make(map[string]*counterTotal)
+	counterTotalsMu      sync.Mutex
+)
+
+// counterDelta returns new-old, treating a decrease as a wrap when the
+// counter has a bit width.
+func counterDelta(old, new float64, width uint) float64 {
+	if width == 0 || new >= old {
+		return new - old
+	}
+	return new + math.Pow(2, float64(width)) - old
+}
+
+// trackCounters advances the unwrapped totals for every "unwrap" counter
+// and returns tag → cumulative value.
+func trackCounters(config *pglogConfig, snapshot map[string]*topicSnapshot) map[string]float64 {
+	if len(config.Counters) == 0 {
+		return nil
+	}
+
+	counterTotalsMu.Lock()
+	defer counterTotalsMu.Unlock()
+
+	totals := make(map[string]float64)
+	for _, topic := range config.Topics {
+		tag := config.parseTopic(topic).Tag
+		counter, ok := config.Counters[tag]
+		if !ok || !counter.Unwrap {
+			continue
+		}
+
+		snap := snapshot[topic]
+		if snap == nil || snap.Current == "" {
+			continue
+		}
+		raw, err := strconv.ParseFloat(strings.TrimSpace(config.compareValue(snap.Current)), 64)
+		if err != nil {
+			continue
+		}
+
+		state, exists := counterTotalsByTopic[topic]
+		if !exists {
+			state = &counterTotal{Raw: raw, Total: raw}
+			counterTotalsByTopic[topic] = state
+		} else {
+			state.Total += counterDelta(state.Raw, raw, counter.Width)
+			state.Raw = raw
+		}
+		totals[tag] = state.Total
+	}
+
+	return totals
+}
@@ -0,0 +1,25 @@
+package function
+
+import "testing"
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new float64
+		width    uint
+		want     float64
+	}{
+		{"increase", 10, 15, 16, 5},
+		{"unchanged", 42, 42, 16, 0},
+		{"no width decrease", 15, 10, 0, -5},
+		{"wrap 16 bit", 65530, 4, 16, 10},
+		{"wrap to zero", 65535, 0, 16, 1},
+		{"wrap 32 bit", 4294967295, 0, 32, 1},
+		{"full range", 0, 65535, 16, 65535},
+	}
+	for _, tt := range tests {
+		if got := counterDelta(tt.old, tt.new, tt.width); got != tt.want {
+			t.Errorf("%s: counterDelta(%v, %v, %d) = %v, want %v", tt.name, tt.old, tt.new, tt.width, got, tt.want)
+		}
+	}
+}
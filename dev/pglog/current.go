@@ -0,0 +1,126 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ── Current State Table ─────────────────────────────────────────────
+// With "maintain_current": true every logged row is also upserted into
+// {table}_current, which holds one row per line (UNS levels + tenant)
+// with the latest value of every tag:
+//
+//	SELECT values->>'temperature' FROM uns_log_current WHERE line = 'line1'
+//
+// Values are merged into the stored ones, so per_tag rows update only
+// their tag. An upsert never replaces a newer logged_at, so replayed dead
+// letters and backfills can't roll the state back. Aggregate window rows
+// are not upserted. The append log is unchanged.
+
+const currentSuffix = "_current"
+
+func currentTable(table string) string {
+	return table + currentSuffix
+}
+
+func validateCurrent(config *pglogConfig) error {
+	if !config.MaintainCurrent {
+		return nil
+	}
+	if err := validateIdentifier(currentTable(config.Table)); err != nil {
+		return fmt.Errorf("maintain_current: %v", err)
+	}
+	return nil
+}
+
+// ensureCurrentTable creates {table}_current, keyed by the line.
+func ensureCurrentTable(ctx context.Context, config *pglogConfig) error {
+	var levelDefs strings.Builder
+	for _, level := range config.levelColumns() {
+		fmt.Fprintf(&levelDefs, "\n\t\t\t%-11s TEXT         NOT NULL,", quoteIdent(level))
+	}
+
+	pool, err := dbFor(config)
+	if err != nil {
+		return err
+	}
+	_, err = pool.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (%s
+			tenant      TEXT         NOT NULL DEFAULT '',
+			values      JSONB        NOT NULL,
+			logged_at   TIMESTAMPTZ  NOT NULL,
+			PRIMARY KEY (%s)
+		)
+	`, quoteIdent(currentTable(config.Table)), levelDefs.String(), strings.Join(currentKey(config), ", ")))
+	return err
+}
+
+// currentKey returns the conflict target of {table}_current.
+func currentKey(config *pglogConfig) []string {
+	key := make([]string, 0, len(config.levelColumns())+1)
+	for _, level := range config.levelColumns() {
+		key = append(key, quoteIdent(level))
+	}
+	return append(key, "tenant")
+}
+
+// buildUpsertCurrent returns the upsert of a row into {table}_current;
+// ok is false when the row doesn't update the current state.
+func buildUpsertCurrent(row logRow) (query string, args []interface{}, ok bool, err error) {
+	if !row.Config.MaintainCurrent || row.Tag == aggregateTag {
+		return "", nil, false, nil
+	}
+
+	valuesJSON, err := json.Marshal(row.Values)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to marshal values: %w", err)
+	}
+
+	var columns []string
+	for _, level := range row.Config.levelColumns() {
+		columns = append(columns, quoteIdent(level))
+		args = append(args, row.UNS.Levels[level])
+	}
+	columns = append(columns, "tenant", "values", "logged_at")
+	var loggedAt interface{}
+	if !row.LoggedAt.IsZero() {
+		loggedAt = row.LoggedAt
+	}
+	args = append(args, tenantID, valuesJSON, loggedAt)
+
+	placeholders := make([]string, len(args))
+	for i := range args {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	placeholders[len(args)-1] = fmt.Sprintf("COALESCE($%d::timestamptz, NOW())", len(args))
+
+	table := quoteIdent(currentTable(row.Config.Table))
+	query = fmt.Sprintf(`INSERT INTO %s AS cur (%s) VALUES (%s)
+		ON CONFLICT (%s) DO UPDATE
+		SET values = cur.values || EXCLUDED.values, logged_at = EXCLUDED.logged_at
+		WHERE cur.logged_at <= EXCLUDED.logged_at`,
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", "),
+		strings.Join(currentKey(row.Config), ", "))
+	return query, args, true, nil
+}
+
+// upsertCurrent updates {table}_current after a row was inserted. The
+// log row stays even if this fails; the next change repairs the state.
+func upsertCurrent(ctx context.Context, row logRow) {
+	query, args, ok, err := buildUpsertCurrent(row)
+	if ok {
+		err = writeQuorum(ctx, row.Config, func(target *pglogConfig) error {
+			pool, err := dbFor(target)
+			if err != nil {
+				return err
+			}
+			_, err = pool.Exec(ctx, query, args...)
+			return err
+		})
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to update current state", "table", currentTable(row.Config.Table), "error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ── Postgres Reconnects ─────────────────────────────────────────────
+// The initial connection is retried with exponential backoff
+// (DB_CONNECT_RETRIES attempts after the first, starting at
+// DB_CONNECT_BACKOFF_MS and doubling). Per-request DB calls that fail
+// with a connection error get one reconnect attempt before erroring.
+//
+// The pool is sized from DATABASE_URL (pool_max_conns etc., or the pgx
+// defaults), overridden by DB_MAX_CONNS, DB_MIN_CONNS,
+// DB_MAX_CONN_LIFETIME_MS and DB_MAX_CONN_IDLE_MS when set. /health
+// reports the pool's acquired/idle/total connections.
+//
+// Row inserts that fail transiently (serialization failures, deadlocks,
+// dropped connections) are retried up to DB_INSERT_RETRIES times after
+// the first attempt, with exponential backoff from DB_INSERT_BACKOFF_MS
+// plus jitter. Other errors (constraint violations, bad SQL) fail at once.
+
+var (
+	dbConnectRetries = envIntOrDefault("DB_CONNECT_RETRIES", 5)
+	dbConnectBackoff = time.Duration(envIntOrDefault("DB_CONNECT_BACKOFF_MS", 500)) * time.Millisecond
+
+	dbInsertRetries = envIntOrDefault("DB_INSERT_RETRIES", 3)
+	dbInsertBackoff = time.Duration(envIntOrDefault("DB_INSERT_BACKOFF_MS", 50)) * time.Millisecond
+)
+
+// newDBPool creates the Postgres pool with the configured sizing.
+func newDBPool(ctx context.Context, dbURL string) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+
+	if n := envIntOrDefault("DB_MAX_CONNS", 0); n > 0 {
+		poolConfig.MaxConns = int32(n)
+	}
+	if n := envIntOrDefault("DB_MIN_CONNS", -1); n >= 0 {
+		poolConfig.MinConns = int32(n)
+	}
+	if ms := envIntOrDefault("DB_MAX_CONN_LIFETIME_MS", 0); ms > 0 {
+		poolConfig.MaxConnLifetime = time.Duration(ms) * time.Millisecond
+	}
+	if ms := envIntOrDefault("DB_MAX_CONN_IDLE_MS", 0); ms > 0 {
+		poolConfig.MaxConnIdleTime = time.Duration(ms) * time.Millisecond
+	}
+	if poolConfig.MinConns > poolConfig.MaxConns {
+		return nil, fmt.Errorf("DB_MIN_CONNS (%d) exceeds the max of %d connections", poolConfig.MinConns, poolConfig.MaxConns)
+	}
+
+	logger.InfoContext(ctx, "Postgres pool configured",
+		"max_conns", poolConfig.MaxConns,
+		"min_conns", poolConfig.MinConns,
+		"max_conn_lifetime", poolConfig.MaxConnLifetime.String(),
+		"max_conn_idle_time", poolConfig.MaxConnIdleTime.String())
+
+	return pgxpool.NewWithConfig(ctx, poolConfig)
+}
+
+// retryWithBackoff calls fn until it succeeds or retries are exhausted,
+// sleeping backoff, 2×backoff, 4×backoff, … between attempts.
+func retryWithBackoff(retries int, backoff time.Duration, fn func() error) error {
+	err := fn()
+	for attempt := 0; err != nil && attempt < retries; attempt++ {
+		delay := backoff << attempt
+		logger.Warn("Attempt failed, retrying", "attempt", attempt+1, "error", err, "delay", delay.String())
+		time.Sleep(delay)
+		err = fn()
+	}
+	return err
+}
+
+// withReconnect runs fn and, if it failed because the connection was
+// lost, re-establishes a connection to pool and runs it once more. The
+// pool drops broken connections itself, so a successful ping is enough.
+func withReconnect(ctx context.Context, pool *pgxpool.Pool, fn func() error) error {
+	err := fn()
+	if err == nil || !isConnectionError(err) {
+		return err
+	}
+
+	logger.WarnContext(ctx, "Postgres connection error, reconnecting", "error", err)
+	if pingErr := pool.Ping(ctx); pingErr != nil {
+		return err
+	}
+	return fn()
+}
+
+// withInsertRetry runs fn, retrying transient errors (see
+// isRetryableError) with jittered exponential backoff. It gives up early
+// when ctx is done.
+func withInsertRetry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 0; err != nil && attempt < dbInsertRetries && isRetryableError(err); attempt++ {
+		delay := jitter(dbInsertBackoff << attempt)
+		logger.WarnContext(ctx, "Insert failed, retrying", "attempt", attempt+1, "error", err, "delay", delay.String())
+		metricInsertRetries.Inc()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = fn()
+	}
+	return err
+}
+
+// jitter returns a random duration in [d/2, d), so concurrent retries
+// don't hit the database in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)))
+}
+
+// retryableSQLStates are server errors worth retrying as is.
+var retryableSQLStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+	"57P01": true, // admin_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isRetryableError reports whether err is transient: a connection error,
+// a connection exception (class 08) or one of retryableSQLStates.
+func isRetryableError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return retryableSQLStates[pgErr.Code] || strings.HasPrefix(pgErr.Code, "08")
+	}
+	return isConnectionError(err)
+}
+
+// isConnectionError reports whether err is a transport-level failure
+// rather than an error returned by the Postgres server.
+func isConnectionError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return false
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,100 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// failingFn returns an fn that fails with err the first failures times.
+func failingFn(failures int, err error) (fn func() error, calls *int) {
+	calls = new(int)
+	return func() error {
+		*calls++
+		if *calls <= failures {
+			return err
+		}
+		return nil
+	}, calls
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	errDown := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		failures  int
+		retries   int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"succeeds at once", 0, 3, 1, false},
+		{"succeeds on retry", 2, 3, 3, false},
+		{"succeeds on last retry", 3, 3, 4, false},
+		{"retries exhausted", 5, 3, 4, true},
+		{"no retries", 1, 0, 1, true},
+	}
+	for _, tt := range tests {
+		fn, calls := failingFn(tt.failures, errDown)
+		err := retryWithBackoff(tt.retries, time.Microsecond, fn)
+		if (err != nil) != tt.wantErr || *calls != tt.wantCalls {
+			t.Errorf("%s: err = %v after %d calls, want error %v after %d", tt.name, err, *calls, tt.wantErr, tt.wantCalls)
+		}
+	}
+}
+
+// withInsertBackoff sets DB_INSERT_RETRIES and DB_INSERT_BACKOFF_MS for a test.
+func withInsertBackoff(t *testing.T, retries int, backoff time.Duration) {
+	t.Helper()
+	oldRetries, oldBackoff := dbInsertRetries, dbInsertBackoff
+	dbInsertRetries, dbInsertBackoff = retries, backoff
+	t.Cleanup(func() { dbInsertRetries, dbInsertBackoff = oldRetries, oldBackoff })
+}
+
+func TestWithInsertRetryCancelled(t *testing.T) {
+	withInsertBackoff(t, 3, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fn, calls := failingFn(10, &pgconn.PgError{Code: "40001"})
+	start := time.Now()
+	err := withInsertRetry(ctx, fn)
+	if err == nil || *calls != 1 {
+		t.Errorf("err = %v after %d calls, want the first error after 1", err, *calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v, want promptly", elapsed)
+	}
+}
+
+func TestWithInsertRetry(t *testing.T) {
+	withInsertBackoff(t, 3, time.Microsecond)
+
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"succeeds at once", 0, nil, 1, false},
+		{"serialization failure", 2, &pgconn.PgError{Code: "40001"}, 3, false},
+		{"deadlock", 1, &pgconn.PgError{Code: "40P01"}, 2, false},
+		{"connection exception", 3, &pgconn.PgError{Code: "08006"}, 4, false},
+		{"connection lost", 1, io.ErrUnexpectedEOF, 2, false},
+		{"retries exhausted", 4, &pgconn.PgError{Code: "40001"}, 4, true},
+		{"constraint violation", 1, &pgconn.PgError{Code: "23505"}, 1, true},
+		{"plain error", 1, errors.New("bad value"), 1, true},
+	}
+	for _, tt := range tests {
+		fn, calls := failingFn(tt.failures, tt.err)
+		err := withInsertRetry(context.Background(), fn)
+		if (err != nil) != tt.wantErr || *calls != tt.wantCalls {
+			t.Errorf("%s: err = %v after %d calls, want error %v after %d", tt.name, err, *calls, tt.wantErr, tt.wantCalls)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package function
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ── Dead Letters ────────────────────────────────────────────────────
+// With DEADLETTER_PREFIX set, rows that fail to insert are written to S3
+// instead of being lost:
+//
+//	s3://{DEADLETTER_BUCKET}/{prefix}/{table}/{timestamp}-{function}.json
+//
+// POST /pglog/replay-deadletter re-inserts the dead letters of the
+// selected config and deletes the ones that succeed, giving at-least-once
+// logging. Replayed rows keep their event time, or else the time the
+// insert failed, as logged_at. Each letter records the config that wrote
+// it and its UNS level columns: letters of other configs are skipped, and
+// letters whose levels no longer match the config's uns_schema fail, so
+// levels never land in the wrong columns. A row that some mirrors
+// already took is replayed to the other databases only.
+
+var (
+	deadLetterPrefix string
+	deadLetterBucket string
+)
+
+type deadLetter struct {
+	Config     string                 `json:"config"` // stateID of the config that wrote it
+	Levels     []string               `json:"levels"` // its UNS level columns
+	Table      string                 `json:"table"`
+	UNS        map[string]string      `json:"uns"`
+	Tag        string                 `json:"tag"`
+	Values     map[string]interface{} `json:"values"`
+	Changed    []string               `json:"changed"`
+	PrevValues map[string]interface{} `json:"prev_values"`
+	Deltas     map[string]float64     `json:"deltas"`
+	Quality    map[string]string      `json:"quality,omitempty"`
+	Unit       string                 `json:"unit,omitempty"`
+	SourceTS   time.Time              `json:"source_ts"`
+	LoggedAt   time.Time              `json:"logged_at"`
+	FailedAt   time.Time              `json:"failed_at"`
+	Error      string                 `json:"error"`
+
+	// Set when some mirrors took the row: the databases still missing it
+	// and how many hold it, see mirrors.go
+	Databases []string `json:"databases,omitempty"`
+	Written   int      `json:"written,omitempty"`
+}
+
+func initDeadLetter() {
+	deadLetterPrefix = envOrDefault("DEADLETTER_PREFIX", "")
+	if deadLetterPrefix == "" {
+		return
+	}
+	deadLetterBucket = envOrDefault("DEADLETTER_BUCKET", envOrDefault("S3_BUCKET", ""))
+	logger.Info("Dead letters enabled", "bucket", deadLetterBucket, "prefix", deadLetterPrefix)
+}
+
+// writeDeadLetter stores a row that failed to insert. It runs with its own
+// S3 timeout, as the request context has usually expired by then.
+func writeDeadLetter(ctx context.Context, row logRow, cause error) (string, error) {
+	if deadLetterPrefix == "" {
+		return "", fmt.Errorf("dead letters not enabled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s3Timeout)
+	defer cancel()
+
+	doc := deadLetter{
+		Config:     row.Config.stateID(),
+		Levels:     row.Config.levelColumns(),
+		Table:      row.Config.Table,
+		UNS:        row.UNS.Levels,
+		Tag:        row.Tag,
+		Values:     row.Values,
+		Changed:    row.Changed,
+		PrevValues: row.PrevValues,
+		Deltas:     row.Deltas,
+		Quality:    row.Quality,
+		Unit:       row.Unit,
+		SourceTS:   row.SourceTS,
+		LoggedAt:   row.LoggedAt,
+		FailedAt:   time.Now().UTC(),
+		Error:      cause.Error(),
+		Databases:  row.Pending,
+		Written:    row.Written,
+	}
+	if doc.LoggedAt.IsZero() {
+		doc.LoggedAt = doc.FailedAt
+	}
+
+	key := fmt.Sprintf("%s/%s/%s-%s.json", deadLetterPrefix, doc.Table,
+		doc.FailedAt.Format("20060102T150405.000000000Z"), envOrDefault("FUNCTION_TARGET", "pglog"))
+	if err := putDeadLetter(ctx, key, doc); err != nil {
+		return "", err
+	}
+
+	logger.WarnContext(ctx, "Row written to dead letter", "table", doc.Table, "key", key, "error", cause)
+	return key, nil
+}
+
+func putDeadLetter(ctx context.Context, key string, doc deadLetter) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(deadLetterBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write s3://%s/%s: %w", deadLetterBucket, key, err)
+	}
+	return nil
+}
+
+// writeDeadLetters stores each row in its own dead letter, returning the
+// keys. It stops at the first row that can't be stored.
+func writeDeadLetters(ctx context.Context, rows []logRow, cause error) ([]string, error) {
+	keys := make([]string, 0, len(rows))
+	for _, row := range rows {
+		key, err := writeDeadLetter(ctx, row, cause)
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func replayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	if deadLetterPrefix == "" {
+		writeError(w, codeNotEnabled, http.StatusNotFound, errors.New("dead letters need DEADLETTER_PREFIX"))
+		return
+	}
+
+	s3Ctx, cancel := context.WithTimeout(r.Context(), s3Timeout)
+	config, err := loadConfig(s3Ctx, r.URL.Query().Get("config"))
+	cancel()
+	if err != nil {
+		status, body := configError(err)
+		writeJSON(w, status, body)
+		return
+	}
+
+	replayed, failed, skipped, err := replayDeadLetters(r.Context(), config)
+	if err != nil {
+		code := codeDeadLetterList
+		if errors.Is(err, errReplayTable) {
+			code = codeEnsureTable
+		}
+		status, body := apiError(code, http.StatusInternalServerError, err)
+		body["replayed"] = replayed
+		body["failed"] = failed
+		body["skipped"] = skipped
+		writeJSON(w, status, body)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"replayed": replayed,
+		"failed":   failed,
+		"skipped":  skipped,
+	})
+}
+
+// errOtherConfig marks dead letters written by another config.
+var errOtherConfig = errors.New("dead letter belongs to another config")
+
+// errReplayTable marks a replay that could not ensure its table.
+var errReplayTable = errors.New("failed to ensure table for replay")
+
+// replayDeadLetters re-inserts the dead letters of the config's table
+// that the config wrote. Objects are deleted once inserted; failures stay
+// for the next replay. Replayed rows publish no change notification, as
+// the change is no longer current.
+func replayDeadLetters(ctx context.Context, config *pglogConfig) (replayed, failed, skipped int, err error) {
+	dbCtx, cancel := context.WithTimeout(ctx, dbTimeout)
+	err = dbWrite(func() error { return ensureTable(dbCtx, config) })
+	cancel()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("%w: %w", errReplayTable, err)
+	}
+
+	pages := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(deadLetterBucket),
+		Prefix: aws.String(deadLetterPrefix + "/" + config.Table + "/"),
+	})
+
+	for pages.HasMorePages() {
+		pageCtx, cancel := context.WithTimeout(ctx, s3Timeout)
+		page, err := pages.NextPage(pageCtx)
+		cancel()
+		if err != nil {
+			return replayed, failed, skipped, err
+		}
+
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			err := replayDeadLetter(ctx, config, key)
+			switch {
+			case errors.Is(err, errOtherConfig):
+				skipped++
+			case err != nil:
+				logger.WarnContext(ctx, "Failed to replay dead letter", "key", key, "error", err)
+				failed++
+			default:
+				replayed++
+			}
+		}
+	}
+
+	return replayed, failed, skipped, nil
+}
+
+func replayDeadLetter(ctx context.Context, config *pglogConfig, key string) error {
+	body, err := readDeadLetter(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read dead letter: %w", err)
+	}
+
+	var doc deadLetter
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("failed to parse dead letter: %w", err)
+	}
+	// Letters from before the config was recorded are matched by table
+	if doc.Table != config.Table || (doc.Config != "" && doc.Config != config.stateID()) {
+		return errOtherConfig
+	}
+	if doc.Levels != nil && !slices.Equal(doc.Levels, config.levelColumns()) {
+		return fmt.Errorf("written with UNS levels %v, config has %v", doc.Levels, config.levelColumns())
+	}
+
+	row := logRow{
+		Config:     config,
+		UNS:        unsFields{Levels: doc.UNS},
+		Tag:        doc.Tag,
+		Values:     doc.Values,
+		Changed:    doc.Changed,
+		PrevValues: doc.PrevValues,
+		Deltas:     doc.Deltas,
+		Quality:    doc.Quality,
+		Unit:       doc.Unit,
+		SourceTS:   doc.SourceTS,
+		LoggedAt:   doc.LoggedAt,
+		Pending:    doc.Databases,
+		Written:    doc.Written,
+		Replayed:   true,
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, dbTimeout)
+	err = dbWrite(func() error { return insertRow(dbCtx, &row) })
+	cancel()
+	if err != nil {
+		// Keep the letter to the databases that still miss the row
+		if row.Written > doc.Written {
+			doc.Databases, doc.Written = row.Pending, row.Written
+			s3Ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+			if putErr := putDeadLetter(s3Ctx, key, doc); putErr != nil {
+				logger.WarnContext(ctx, "Failed to update dead letter", "key", key, "error", putErr)
+			}
+			cancel()
+		}
+		return err
+	}
+	countInserted(ctx, 1)
+
+	s3Ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+	_, err = s3Client.DeleteObject(s3Ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(deadLetterBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("inserted but failed to delete dead letter: %w", err)
+	}
+	return nil
+}
+
+func readDeadLetter(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	result, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(deadLetterBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter: %w", err)
+	}
+	defer result.Body.Close()
+
+	return io.ReadAll(result.Body)
+}
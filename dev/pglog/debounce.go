@@ -0,0 +1,71 @@
+package function
+
+import (
+	"time"
+)
+
+// ── Debounce ────────────────────────────────────────────────────────
+// Flapping tags can be limited to one logged change per interval:
+//
+//	"min_interval_seconds": 5,
+//	"min_interval": { "alarm": 0, "speed": 30 }
+//
+// After a tag is logged, further changes to it are ignored until the
+// interval has elapsed. Its last value isn't advanced meanwhile, so the
+// value current at the first invocation after the window is logged.
+// The timestamps live in process memory only: debouncing is best effort
+// and restarts (or multiple replicas) start with a clean slate.
+
+var lastLoggedAt = make(map[string]time.Time) // stateKey → last logged change, guarded by lastSnapshotMu
+
+func (c *pglogConfig) minIntervalFor(tag string) time.Duration {
+	seconds, ok := c.MinInterval[tag]
+	if !ok {
+		seconds = c.MinIntervalSeconds
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// debounced reports whether a change to the topic falls within the
+// tag's interval. Callers must hold lastSnapshotMu.
+func debounced(config *pglogConfig, topic, tag string) bool {
+	interval := config.minIntervalFor(tag)
+	if interval <= 0 {
+		return false
+	}
+	last, ok := lastLoggedAt[config.stateKey(topic)]
+	return ok && time.Since(last) < interval
+}
+
+// recordLogged starts the debounce interval of every changed tag.
+func recordLogged(config *pglogConfig, changed []string) {
+	if config.MinIntervalSeconds <= 0 && len(config.MinInterval) == 0 {
+		return
+	}
+
+	tags := make(map[string]bool, len(changed))
+	for _, entry := range changed {
+		tags[tagOfChange(entry)] = true
+	}
+
+	lastSnapshotMu.Lock()
+	defer lastSnapshotMu.Unlock()
+
+	now := time.Now()
+	for _, topic := range config.Topics {
+		if tags[config.parseTopic(topic).Tag] {
+			lastLoggedAt[config.stateKey(topic)] = now
+		}
+	}
+}
+
+// debouncedTags returns the tags whose change was suppressed.
+func debouncedTags(config *pglogConfig, snapshot map[string]*topicSnapshot) []string {
+	var tags []string
+	for _, topic := range config.Topics {
+		if snap := snapshot[topic]; snap != nil && snap.Debounced {
+			tags = append(tags, config.parseTopic(topic).Tag)
+		}
+	}
+	return tags
+}
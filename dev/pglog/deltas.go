@@ -0,0 +1,62 @@
+package function
+
+import (
+	"context"
+)
+
+// ── Previous Values & Deltas ────────────────────────────────────────
+// Every row also stores what its values were compared against, so step
+// sizes and rates of change don't need a self-join:
+//
+//	prev_values  JSONB  tag → previous value (last logged, or uns:prev)
+//	deltas       JSONB  tag → new - old, for numeric changed tags
+//
+// Deltas of wrapping counters account for the wrap (see counters.go).
+
+// previousValues returns the values the current snapshot was compared
+// against in detectChanges.
+func previousValues(ctx context.Context, config *pglogConfig, snapshot map[string]*topicSnapshot) map[string]interface{} {
+	lastSnapshotMu.Lock()
+	defer lastSnapshotMu.Unlock()
+
+	usePrev := config.ChangeSource == changeSourcePrev
+	if !usePrev {
+		loadLastSnapshot(ctx, config)
+	}
+
+	prev := make(map[string]interface{})
+	for _, topic := range config.Topics {
+		tag := config.parseTopic(topic).Tag
+
+		last := lastSnapshot[config.stateKey(topic)]
+		if usePrev {
+			last = ""
+			if snap := snapshot[topic]; snap != nil {
+				last = snap.Previous
+			}
+		}
+
+		if last == "" {
+			prev[tag] = nil
+			continue
+		}
+		prev[tag] = parseValue(config.compareValue(last))
+	}
+
+	return prev
+}
+
+// computeDeltas returns new - old for every changed tag whose previous
+// and current values are both numbers.
+func computeDeltas(config *pglogConfig, prev, values map[string]interface{}, changed []string) map[string]float64 {
+	deltas := make(map[string]float64)
+	for _, entry := range changed {
+		tag := tagOfChange(entry)
+		oldNum, oldOK := prev[tag].(float64)
+		newNum, newOK := values[tag].(float64)
+		if oldOK && newOK {
+			deltas[tag] = counterDelta(oldNum, newNum, config.Counters[tag].Width)
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,74 @@
+package function
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ── Edge Detection ──────────────────────────────────────────────────
+// For discrete signals only one transition direction may be of interest:
+//
+//	"edge": { "alarm": "rising", "running": "both" }
+//
+// When both the last and the current value parse as booleans, a tag with
+// an edge only counts as changed on the configured transition, and is
+// reported as "alarm:rising" / "running:falling" in changed. Ignored
+// transitions still advance the tag's last value, so the next onset is
+// seen. Values that aren't booleans fall back to normal detection.
+
+const (
+	edgeRising  = "rising"
+	edgeFalling = "falling"
+	edgeBoth    = "both"
+)
+
+func validateEdges(edges map[string]string) error {
+	for tag, edge := range edges {
+		switch edge {
+		case edgeRising, edgeFalling, edgeBoth:
+		default:
+			return fmt.Errorf("edge.%s must be %q, %q or %q", tag, edgeRising, edgeFalling, edgeBoth)
+		}
+	}
+	return nil
+}
+
+// detectEdge classifies a boolean transition. ok is false when either
+// value isn't a boolean; edge is "" when the transition isn't configured.
+func detectEdge(mode, last, current string) (edge string, ok bool) {
+	oldBool, oldErr := strconv.ParseBool(strings.TrimSpace(last))
+	newBool, newErr := strconv.ParseBool(strings.TrimSpace(current))
+	if oldErr != nil || newErr != nil {
+		return "", false
+	}
+
+	switch {
+	case !oldBool && newBool && (mode == edgeRising || mode == edgeBoth):
+		return edgeRising, true
+	case oldBool && !newBool && (mode == edgeFalling || mode == edgeBoth):
+		return edgeFalling, true
+	}
+	return "", true
+}
+
+// tagOfChange strips the edge suffix from a changed entry.
+func tagOfChange(entry string) string {
+	tag, _, _ := strings.Cut(entry, ":")
+	return tag
+}
+
+// edgeTopics returns the topics of tags with edge detection, whose last
+// value is tracked on every invocation rather than only when logging.
+func edgeTopics(config *pglogConfig) []string {
+	if len(config.Edge) == 0 {
+		return nil
+	}
+	var topics []string
+	for _, topic := range config.Topics {
+		if _, ok := config.Edge[config.parseTopic(topic).Tag]; ok {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
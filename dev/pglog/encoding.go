@@ -0,0 +1,157 @@
+package function
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ── Value Encodings ─────────────────────────────────────────────────
+// "value_encoding" says how upstreams write cache values:
+//
+//	"json" (default)  JSON, or bare numbers and strings (see parseValue)
+//	"raw"             plain text, never decoded as JSON: numbers and
+//	                  true/false are coerced, anything else is a string
+//	"protobuf"        a binary Reading message (needs value_schema "vtq")
+//
+// Values are decoded as they are read from the cache, so change detection
+// and storage see the same JSON whatever the wire format. The protobuf
+// message has a fixed schema; unknown fields are skipped:
+//
+//	message Reading {
+//	  double value      = 1;
+//	  int64  ts         = 2; // epoch milliseconds
+//	  string quality    = 3;
+//	  string unit       = 4;
+//	  string text_value = 5; // instead of value, for string readings
+//	  bool   bool_value = 6; // instead of value, for boolean readings
+//	}
+//
+// A value that doesn't decode is treated as missing and logged as a
+// warning.
+
+const (
+	valueEncodingJSON     = "json"
+	valueEncodingRaw      = "raw"
+	valueEncodingProtobuf = "protobuf"
+)
+
+func validateValueEncoding(config *pglogConfig) error {
+	switch config.ValueEncoding {
+	case valueEncodingJSON:
+	case valueEncodingRaw:
+		if config.ValueSchema == valueSchemaVTQ {
+			return fmt.Errorf("value_encoding %q can't carry value_schema %q payloads", valueEncodingRaw, valueSchemaVTQ)
+		}
+	case valueEncodingProtobuf:
+		if config.ValueSchema != valueSchemaVTQ {
+			return fmt.Errorf("value_encoding %q needs value_schema %q", valueEncodingProtobuf, valueSchemaVTQ)
+		}
+	default:
+		return fmt.Errorf("value_encoding must be %q, %q or %q", valueEncodingJSON, valueEncodingRaw, valueEncodingProtobuf)
+	}
+	return nil
+}
+
+// decodeSnapshot rewrites every current and previous value as JSON.
+func decodeSnapshot(config *pglogConfig, snapshot map[string]*topicSnapshot) {
+	if config.ValueEncoding == valueEncodingJSON {
+		return
+	}
+	for topic, snap := range snapshot {
+		raw := snap.Current
+		snap.Current = config.decodeValue(topic, raw)
+		if snap.Current == "" && raw != "" {
+			snap.Rejected, snap.Reason = raw, reasonUndecodable
+		}
+		snap.Previous = config.decodeValue(topic, snap.Previous)
+	}
+}
+
+// decodeValue returns a raw cache value as JSON, "" if it doesn't decode.
+func (c *pglogConfig) decodeValue(topic, raw string) string {
+	if raw == "" {
+		return ""
+	}
+	switch c.ValueEncoding {
+	case valueEncodingRaw:
+		encoded, _ := json.Marshal(coerceString(raw))
+		return string(encoded)
+	case valueEncodingProtobuf:
+		reading, err := decodeProtoReading([]byte(raw))
+		if err != nil {
+			logger.Warn("Skipping undecodable protobuf value", "topic", topic, "error", err)
+			return ""
+		}
+		encoded, _ := json.Marshal(reading)
+		return string(encoded)
+	}
+	return raw
+}
+
+// decodeProtoReading decodes a Reading message into a vtq payload.
+func decodeProtoReading(b []byte) (map[string]interface{}, error) {
+	reading := make(map[string]interface{})
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("malformed field key")
+		}
+		b = b[n:]
+		field, wireType := key>>3, key&7
+
+		var varint uint64
+		var fixed64 uint64
+		var bytes []byte
+		switch wireType {
+		case 0:
+			if varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, fmt.Errorf("field %d: malformed varint", field)
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return nil, fmt.Errorf("field %d: truncated fixed64", field)
+			}
+			fixed64, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return nil, fmt.Errorf("field %d: truncated bytes", field)
+			}
+			bytes, b = b[n:n+int(length)], b[n+int(length):]
+		case 5:
+			if len(b) < 4 {
+				return nil, fmt.Errorf("field %d: truncated fixed32", field)
+			}
+			b = b[4:]
+		default:
+			return nil, fmt.Errorf("field %d: unsupported wire type %d", field, wireType)
+		}
+
+		switch {
+		case field == 1 && wireType == 1:
+			f := math.Float64frombits(fixed64)
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, fmt.Errorf("value is not a finite number")
+			}
+			reading["value"] = json.RawMessage(strconv.FormatFloat(f, 'f', -1, 64))
+		case field == 2 && wireType == 0:
+			reading["ts"] = int64(varint)
+		case field == 3 && wireType == 2:
+			reading["quality"] = string(bytes)
+		case field == 4 && wireType == 2:
+			reading["unit"] = string(bytes)
+		case field == 5 && wireType == 2:
+			reading["value"] = string(bytes)
+		case field == 6 && wireType == 0:
+			reading["value"] = varint != 0
+		}
+	}
+	if _, ok := reading["value"]; !ok {
+		return nil, fmt.Errorf("message has no value")
+	}
+	return reading, nil
+}
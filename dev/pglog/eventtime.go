@@ -0,0 +1,61 @@
+package function
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	_ "time/tzdata" // DISPLAY_TZ works in images without zoneinfo
+)
+
+// ── Event Time ──────────────────────────────────────────────────────
+// Rows are stamped with the insert time (logged_at DEFAULT NOW()) unless
+// an event time is known:
+//
+//	X-Event-Time header      RFC 3339 or epoch milliseconds, per request
+//	"logged_at": "source_ts" the trigger tag's vtq ts (see vtq.go)
+//
+// The header wins over the config. Timestamps in responses are rendered
+// in DISPLAY_TZ (an IANA zone, e.g. "Europe/London"), defaulting to the
+// process zone (TZ, usually UTC). Postgres always stores TIMESTAMPTZ.
+
+const loggedAtSourceTS = "source_ts"
+
+var displayLocation = time.Local
+
+func initDisplayTZ() {
+	name := envOrDefault("DISPLAY_TZ", "")
+	if name == "" {
+		return
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fatal("Invalid DISPLAY_TZ", "value", name, "error", err)
+	}
+	displayLocation = loc
+}
+
+func validateLoggedAt(config *pglogConfig) error {
+	switch config.LoggedAt {
+	case "":
+		return nil
+	case loggedAtSourceTS:
+		if config.ValueSchema != valueSchemaVTQ {
+			return fmt.Errorf("logged_at %q requires value_schema %q", loggedAtSourceTS, valueSchemaVTQ)
+		}
+		return nil
+	default:
+		return fmt.Errorf("logged_at must be empty or %q", loggedAtSourceTS)
+	}
+}
+
+// parseEventTime parses an RFC 3339 timestamp or epoch milliseconds.
+func parseEventTime(raw string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return ts, nil
+	}
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("event time %q is neither RFC 3339 nor epoch milliseconds", raw)
+	}
+	return time.UnixMilli(ms), nil
+}
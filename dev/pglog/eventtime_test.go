@@ -0,0 +1,32 @@
+package function
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEventTime(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-03-01T12:00:00Z", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"2024-03-01T12:00:00.123456789Z", time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.UTC), false},
+		{"2024-03-01T13:00:00+01:00", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"1709294400000", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"1709294400123", time.Date(2024, 3, 1, 12, 0, 0, 123000000, time.UTC), false},
+		{"0", time.Unix(0, 0), false},
+		{"", time.Time{}, true},
+		{"2024-03-01", time.Time{}, true},
+		{"2024-03-01 12:00:00", time.Time{}, true},
+		{"1709294400.5", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseEventTime(tt.raw)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseEventTime(%q) = %v, %v, want %v (error %v)", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package function
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ── CSV Export ──────────────────────────────────────────────────────
+// POST /pglog/export?from=2026-02-01T00:00:00Z&to=2026-02-02T00:00:00Z&line=line1
+//
+// Writes the rows logged in [from, to) — RFC 3339 or epoch milliseconds,
+// filtered by any UNS levels like /latest — as CSV to
+//
+//	s3://{EXPORT_BUCKET}/{EXPORT_PREFIX}/{table}/{from}-{to}.csv
+//
+// with one line per row: logged_at, the level columns, then one column
+// per tag (the union of the values keys over the range, sorted). Rows are
+// streamed from Postgres into a multipart upload, so large ranges aren't
+// held in memory. Rows of other tenants are never exported.
+
+var (
+	exportBucket  = envOrDefault("EXPORT_BUCKET", envOrDefault("S3_BUCKET", ""))
+	exportPrefix  = strings.Trim(envOrDefault("EXPORT_PREFIX", "exports"), "/")
+	exportTimeout = time.Duration(envIntOrDefault("EXPORT_TIMEOUT_MS", 300000)) * time.Millisecond
+)
+
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, to, err := parseRange(query)
+	if err != nil {
+		writeError(w, codeBadRequest, http.StatusBadRequest, err)
+		return
+	}
+	if exportBucket == "" {
+		writeError(w, codeNotEnabled, http.StatusNotFound, errors.New("export needs EXPORT_BUCKET or S3_BUCKET"))
+		return
+	}
+
+	s3Ctx, cancel := context.WithTimeout(r.Context(), s3Timeout)
+	config, err := loadConfig(s3Ctx, query.Get("config"))
+	cancel()
+	if err != nil {
+		status, body := configError(err)
+		writeJSON(w, status, body)
+		return
+	}
+
+	where, args := rangeFilter(config, query, from, to)
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	tags, err := exportTags(ctx, config, where, args)
+	if err != nil {
+		writeError(w, codeQuery, http.StatusInternalServerError, err)
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s/%s-%s.csv", exportPrefix, config.Table,
+		from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"))
+
+	rows, err := uploadExport(ctx, config, tags, where, args, key)
+	if err != nil {
+		writeError(w, codeExport, http.StatusInternalServerError, err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Exported rows", "table", config.Table, "rows", rows, "bucket", exportBucket, "key", key)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"exported": true,
+		"bucket":   exportBucket,
+		"key":      key,
+		"rows":     rows,
+		"tags":     tags,
+	})
+}
+
+// parseRange reads the from and to parameters of a range request.
+func parseRange(query url.Values) (time.Time, time.Time, error) {
+	from, err := parseEventTime(query.Get("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from: %w", err)
+	}
+	to, err := parseEventTime(query.Get("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to: %w", err)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+// rangeFilter returns the WHERE clause and arguments selecting the
+// tenant's rows logged in [from, to) that match the UNS level filters.
+func rangeFilter(config *pglogConfig, query url.Values, from, to time.Time) (string, []interface{}) {
+	// Filter on the declared UNS levels only; column names never come
+	// from the request.
+	conditions := []string{"tenant = $1", "logged_at >= $2", "logged_at < $3"}
+	args := []interface{}{tenantID, from, to}
+	for _, level := range config.levelColumns() {
+		if value := query.Get(level); value != "" {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", quoteIdent(level), len(args)))
+		}
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// exportTags returns the union of the values keys of the matching rows.
+func exportTags(ctx context.Context, config *pglogConfig, where string, args []interface{}) ([]string, error) {
+	pool, err := dbFor(config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT DISTINCT jsonb_object_keys(values) AS tag
+		FROM %s
+		WHERE %s
+		ORDER BY tag
+	`, quoteIdent(config.Table), where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// uploadExport streams the matching rows as CSV into the S3 object and
+// returns the number of rows written.
+func uploadExport(ctx context.Context, config *pglogConfig, tags []string, where string, args []interface{}, key string) (int, error) {
+	pr, pw := io.Pipe()
+	written := make(chan int, 1)
+	go func() {
+		n, err := writeExportCSV(ctx, pw, config, tags, where, args)
+		written <- n
+		pw.CloseWithError(err)
+	}()
+
+	uploader := manager.NewUploader(s3Client)
+	_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(exportBucket),
+		Key:         aws.String(key),
+		Body:        pr,
+		ContentType: aws.String("text/csv"),
+	})
+	// Unblocks the writer if the upload stopped reading early
+	pr.CloseWithError(err)
+	n := <-written
+	if err != nil {
+		return n, fmt.Errorf("failed to write s3://%s/%s: %w", exportBucket, key, err)
+	}
+	return n, nil
+}
+
+func writeExportCSV(ctx context.Context, out io.Writer, config *pglogConfig, tags []string, where string, args []interface{}) (int, error) {
+	levels := config.levelColumns()
+	columns := []string{"logged_at"}
+	for _, level := range levels {
+		columns = append(columns, quoteIdent(level))
+	}
+
+	pool, err := dbFor(config)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT %s, values
+		FROM %s
+		WHERE %s
+		ORDER BY logged_at
+	`, strings.Join(columns, ", "), quoteIdent(config.Table), where), args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	w := csv.NewWriter(out)
+	header := append(append([]string{"logged_at"}, levels...), tags...)
+	if err := w.Write(header); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for rows.Next() {
+		var loggedAt time.Time
+		levelValues := make([]string, len(levels))
+		var raw json.RawMessage
+
+		dest := []interface{}{&loggedAt}
+		for i := range levelValues {
+			dest = append(dest, &levelValues[i])
+		}
+		dest = append(dest, &raw)
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return n, fmt.Errorf("row at %s: %w", loggedAt.Format(time.RFC3339Nano), err)
+		}
+
+		record := append([]string{loggedAt.UTC().Format(time.RFC3339Nano)}, levelValues...)
+		for _, tag := range tags {
+			record = append(record, csvCell(values[tag]))
+		}
+		if err := w.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+
+	w.Flush()
+	return n, w.Error()
+}
+
+// csvCell renders a JSON value for CSV: strings unquoted, null and absent
+// values empty, everything else as JSON.
+func csvCell(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
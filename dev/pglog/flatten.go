@@ -0,0 +1,120 @@
+package function
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ── Flattened Payloads ──────────────────────────────────────────────
+// With "flatten": true, a cache value that is a JSON object or array is
+// split into one value per leaf, keyed by the tag and the dotted path:
+//
+//	motor → {"rpm": 1500, "temp": 60, "alarms": ["low"]}
+//	motor.rpm = 1500, motor.temp = 60, motor.alarms.0 = "low"
+//
+// Each leaf stands in for the topic as a topic of its own (the topic plus
+// ".rpm" etc.), so it is compared, deadbanded and logged independently
+// and has its own entry in the last snapshot. Scalar values are left as
+// they are. Leaves of a topic with "trigger": false don't trigger either.
+
+func validateFlatten(config *pglogConfig) error {
+	if config.Flatten && config.ValueSchema == valueSchemaVTQ {
+		return fmt.Errorf("flatten can't be combined with value_schema %q", valueSchemaVTQ)
+	}
+	return nil
+}
+
+// flattenSnapshot replaces every object or array value in snapshot with
+// its leaves, returning a config whose topics list the leaf topics in
+// place of the original ones.
+func flattenSnapshot(config *pglogConfig, snapshot map[string]*topicSnapshot) (*pglogConfig, map[string]*topicSnapshot) {
+	flat := *config
+	flat.Topics = make([]string, 0, len(config.Topics))
+	flat.NoTrigger = make(map[string]bool, len(config.NoTrigger))
+	for topic, noTrigger := range config.NoTrigger {
+		flat.NoTrigger[topic] = noTrigger
+	}
+	flatSnapshot := make(map[string]*topicSnapshot, len(snapshot))
+
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		var current map[string]string
+		if snap != nil {
+			current = flattenValue(snap.Current)
+		}
+		if len(current) == 0 {
+			flat.Topics = append(flat.Topics, topic)
+			flatSnapshot[topic] = snap
+			continue
+		}
+
+		previous := flattenValue(snap.Previous)
+		paths := make([]string, 0, len(current))
+		for path := range current {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			leaf := topic + "." + path
+			flat.Topics = append(flat.Topics, leaf)
+			flatSnapshot[leaf] = &topicSnapshot{Current: current[path], Previous: previous[path]}
+			if !config.triggers(topic) {
+				flat.NoTrigger[leaf] = true
+			}
+		}
+	}
+
+	return &flat, flatSnapshot
+}
+
+// flattenValue returns path → raw leaf value for a JSON object or array,
+// or nil for anything else.
+func flattenValue(raw string) map[string]string {
+	trimmed := bytes.TrimSpace([]byte(raw))
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil
+	}
+
+	leaves := make(map[string]string)
+	flattenInto(leaves, "", parsed)
+	return leaves
+}
+
+func flattenInto(leaves map[string]string, prefix string, value interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenInto(leaves, join(key), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenInto(leaves, join(strconv.Itoa(i)), child)
+		}
+	case string:
+		leaves[prefix] = v
+	case json.Number:
+		leaves[prefix] = v.String()
+	case bool:
+		leaves[prefix] = strconv.FormatBool(v)
+	case nil:
+		leaves[prefix] = "null"
+	}
+}
@@ -0,0 +1,29 @@
+package function
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want map[string]string
+	}{
+		{`{"rpm": 1500, "temp": 60, "alarms": ["low"]}`, map[string]string{"rpm": "1500", "temp": "60", "alarms.0": "low"}},
+		{`{"a": {"b": {"c": true}}}`, map[string]string{"a.b.c": "true"}},
+		{`[1.50, null, "x"]`, map[string]string{"0": "1.50", "1": "null", "2": "x"}},
+		{` {"id": 9007199254740993} `, map[string]string{"id": "9007199254740993"}},
+		{`{}`, map[string]string{}},
+		{`{"empty": {}, "list": []}`, map[string]string{}},
+		{"72.5", nil},
+		{`"{\"a\": 1}"`, nil},
+		{"", nil},
+		{`{"a": 1`, nil},
+	}
+	for _, tt := range tests {
+		if got := flattenValue(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("flattenValue(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestValidateIdentifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"uns_log", false},
+		{"_private", false},
+		{"Line1", false},
+		{strings.Repeat("a", maxIdentifierLen), false},
+		{"", true},
+		{strings.Repeat("a", maxIdentifierLen+1), true},
+		{"1table", true},
+		{"uns-log", true},
+		{"uns log", true},
+		{`uns_log"; DROP TABLE users; --`, true},
+		{"uns_log; DROP TABLE users", true},
+		{`"uns_log"`, true},
+		{"public.uns_log", true},
+		{"tãble", true},
+	}
+	for _, tt := range tests {
+		err := validateIdentifier(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateIdentifier(%q) = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"uns_log", `"uns_log"`},
+		{"Line1", `"Line1"`},
+		{`a"b`, `"a""b"`},
+		{`x"; DROP TABLE users; --`, `"x""; DROP TABLE users; --"`},
+	}
+	for _, tt := range tests {
+		if got := quoteIdent(tt.name); got != tt.want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRequireMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{http.MethodPost, true},
+		{http.MethodGet, false},
+		{http.MethodHead, false},
+		{http.MethodPut, false},
+		{http.MethodDelete, false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		got := requireMethod(rec, httptest.NewRequest(tt.method, "/pglog", nil), http.MethodPost)
+		if got != tt.want {
+			t.Errorf("requireMethod(%s) = %v, want %v", tt.method, got, tt.want)
+		}
+		if !got && (rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost) {
+			t.Errorf("%s: status %d, Allow %q", tt.method, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestPglogHandlerRejectsGet(t *testing.T) {
+	for _, target := range []string{"/pglog", "/pglog/backfill", "/pglog/snapshot", "/pglog/export", "/pglog/reload-config"} {
+		before := scrapeMetric(t, "pglog_invocations_total")
+		rec := httptest.NewRecorder()
+		pglogHandler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("GET %s = %d, want %d", target, rec.Code, http.StatusMethodNotAllowed)
+		}
+		if scrapeMetric(t, "pglog_invocations_total") != before {
+			t.Errorf("GET %s started a logging run", target)
+		}
+	}
+}
+
+// testConfig parses a single config as loadConfig would.
+func testConfig(t *testing.T, body string) *pglogConfig {
+	t.Helper()
+	configs, err := parseConfigs([]byte(body))
+	if err != nil {
+		t.Fatalf("parseConfigs: %v", err)
+	}
+	return configs[0]
+}
+
+// preparedChanges runs a change_source "prev" snapshot of one topic
+// through the value preparation runLog applies before detection
+// (transform, then round) and returns what detectChanges and
+// buildValuesJSON make of it.
+func preparedChanges(t *testing.T, config *pglogConfig, current, previous string) ([]string, interface{}) {
+	t.Helper()
+	topic := config.Topics[0]
+	snapshot := map[string]*topicSnapshot{topic: {Current: current, Previous: previous}}
+	transformSnapshot(config, snapshot)
+	roundSnapshot(config, snapshot)
+	changed, _ := detectChanges(context.Background(), config, snapshot)
+	return changed, buildValuesJSON(config, snapshot)[config.parseTopic(topic).Tag]
+}
+
+// dryRunChanges writes current and previous as the cache values of the
+// config's one topic, under a key prefix of its own, and returns the
+// changes and the value a dry run of runLog reports. The config must use
+// change_source "prev".
+func dryRunChanges(t *testing.T, config *pglogConfig, current, previous string) ([]string, interface{}) {
+	t.Helper()
+	ctx := context.Background()
+	topic := config.Topics[0]
+	config.CacheKeyPrefix = "pglogtest" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	data, prev := config.cacheKey("data", topic), config.cacheKey("prev", topic)
+	defer cache.Del(ctx, data, prev)
+	if err := cache.MSet(ctx, data, current, prev, previous).Err(); err != nil {
+		t.Fatalf("set %s: %v", topic, err)
+	}
+
+	status, body := runLog(ctx, logOptions{DryRun: true, BodyConfig: config})
+	resp, _ := body.(map[string]interface{})
+	if status != http.StatusOK {
+		t.Fatalf("dry run = %d %v", status, body)
+	}
+	changed, _ := resp["changed"].([]string)
+	values, _ := resp["values"].(map[string]interface{})
+	return changed, values[config.parseTopic(topic).Tag]
+}
+
+func TestDetectChangesPrev(t *testing.T) {
+	const topic = "v1.0/acme/plant1/press/line1/temp"
+	tests := []struct {
+		name       string
+		logInitial string
+		previous   string
+		current    string
+		want       []string
+	}{
+		{"first value", "true", "", "72", []string{"temp"}},
+		{"first value not logged", "false", "", "72", nil},
+		{"no value yet", "true", "", "", nil},
+		{"unchanged", "true", "72", "72", nil},
+		{"changed", "true", "72", "73", []string{"temp"}},
+		{"cleared", "true", "72", "", nil},
+	}
+	for _, tt := range tests {
+		config := testConfig(t, `{"topics": ["`+topic+`"], "change_source": "prev", "log_initial": `+tt.logInitial+`}`)
+		snapshot := map[string]*topicSnapshot{topic: {Current: tt.current, Previous: tt.previous}}
+
+		changed, _ := detectChanges(context.Background(), config, snapshot)
+		if !slices.Equal(changed, tt.want) {
+			t.Errorf("%s: changed = %v, want %v", tt.name, changed, tt.want)
+		}
+		if snapshot[topic].Initial {
+			t.Errorf("%s: first value flagged for seeding under change_source prev", tt.name)
+		}
+	}
+}
+
+func TestValidateTopics(t *testing.T) {
+	tests := []struct {
+		name    string
+		topics  []string
+		want    []string
+		wantErr string
+	}{
+		{"valid", []string{"v1.0/acme/line1/temp", "v1.0/acme/line1/rpm"}, []string{"v1.0/acme/line1/temp", "v1.0/acme/line1/rpm"}, ""},
+		{"duplicates dropped", []string{"v1.0/acme/temp", "v1.0/acme/rpm", "v1.0/acme/temp"}, []string{"v1.0/acme/temp", "v1.0/acme/rpm"}, ""},
+		{"empty", []string{"v1.0/acme/temp", ""}, nil, "topics[1]: empty topic"},
+		{"blank", []string{"  "}, nil, "topics[0]: empty topic"},
+		{"one segment", []string{"temp"}, nil, `topics[0]: "temp" needs at least version/enterprise segments`},
+		{"empty segment", []string{"v1.0//temp"}, nil, "needs at least version/enterprise segments"},
+		{"leading slash", []string{"/acme/temp"}, nil, "needs at least version/enterprise segments"},
+		{"reserved character", []string{"v1.0/acme:other/temp"}, nil, "reserved character"},
+		{"all problems listed", []string{"", "temp"}, nil, "topics[0]: empty topic; topics[1]"},
+	}
+	for _, tt := range tests {
+		got, err := validateTopics(tt.topics)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: validateTopics = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want interface{}
+	}{
+		{"72.5", 72.5},
+		{`"72.5"`, 72.5},
+		{" 72.5 ", 72.5},
+		{"-3", -3.0},
+		{"true", true},
+		{`"true"`, true},
+		{"FALSE", false},
+		{`{"x":1}`, map[string]interface{}{"x": 1.0}},
+		{`[1,"a"]`, []interface{}{1.0, "a"}},
+		{"GOOD", "GOOD"},
+		{`"GOOD"`, "GOOD"},
+		{"NaN", "NaN"},
+		{"Inf", "Inf"},
+		{"null", nil},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parseValue(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseValue(%q) = %#v, want %#v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	cmd := func(err error) redis.Cmder {
+		c := redis.NewStringCmd(context.Background(), "get", "k")
+		c.SetErr(err)
+		return c
+	}
+	errRefused := errors.New("dial tcp: connection refused")
+
+	tests := []struct {
+		name    string
+		cmds    []redis.Cmder
+		execErr error
+		want    error
+	}{
+		{"all hits", []redis.Cmder{cmd(nil), cmd(nil)}, nil, nil},
+		{"key misses", []redis.Cmder{cmd(redis.Nil), cmd(nil)}, redis.Nil, nil},
+		{"transport error", []redis.Cmder{cmd(errRefused), cmd(errRefused)}, errRefused, errRefused},
+		{"transport error after a miss", []redis.Cmder{cmd(redis.Nil), cmd(errRefused)}, redis.Nil, errRefused},
+		{"exec error only", []redis.Cmder{cmd(nil)}, errRefused, errRefused},
+	}
+	for _, tt := range tests {
+		if got := pipelineError(tt.cmds, tt.execErr); !errors.Is(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("%s: pipelineError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunLogUnreachableCache(t *testing.T) {
+	unreachable, err := newCacheClient("redis://127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("newCacheClient: %v", err)
+	}
+	old := cache
+	cache = unreachable
+	t.Cleanup(func() {
+		cache = old
+		unreachable.Close()
+	})
+
+	config := testConfig(t, `{"topics": ["v1.0/acme/plant1/press/line1/temp"]}`)
+	status, body := runLog(context.Background(), logOptions{DryRun: true, BodyConfig: config})
+
+	resp, _ := body.(map[string]interface{})
+	apiErr, _ := resp["error"].(apiErrorBody)
+	if status < http.StatusInternalServerError || apiErr.Code != codeCacheRead {
+		t.Errorf("runLog = %d %v, want a %s error", status, body, codeCacheRead)
+	}
+}
+
+func TestValueChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		last     string
+		current  string
+		deadband float64
+		width    uint
+		want     bool
+	}{
+		{"same", "72.5", "72.5", 0, 0, false},
+		{"different", "72.5", "72.6", 0, 0, true},
+		{"number as written", "72.50", "72.5", 0, 0, true},
+		{"inside deadband", "72.5", "72.9", 0.5, 0, false},
+		{"outside deadband", "72.5", "73.0", 0.5, 0, true},
+		{"deadband non-numeric", "OFF", "ON", 0.5, 0, true},
+		{"counter wrap", "65535", "0", 0, 16, true},
+		{"counter wrap inside deadband", "65535", "2", 5, 16, false},
+		{"reordered keys", `{"a": 1, "b": [1, 2]}`, `{"b": [1, 2], "a": 1}`, 0, 0, false},
+		{"whitespace", `{"a":1,"b":{"c":"x"}}`, "{\n  \"a\": 1,\n  \"b\": {\"c\": \"x\"}\n}", 0, 0, false},
+		{"array order", `[1, 2]`, `[2, 1]`, 0, 0, true},
+		{"object value", `{"a": 1}`, `{"a": 2}`, 0, 0, true},
+		{"large integers", `{"id": 9007199254740993}`, `{"id": 9007199254740992}`, 0, 0, true},
+		{"large integers equal", `{"id": 9007199254740993}`, `{ "id": 9007199254740993 }`, 0, 0, false},
+		{"trailing data", `{"a": 1}`, `{"a": 1} {"b": 2}`, 0, 0, true},
+		{"object and array", `{}`, `[]`, 0, 0, true},
+	}
+	for _, tt := range tests {
+		if got := valueChanged(tt.last, tt.current, tt.deadband, tt.width); got != tt.want {
+			t.Errorf("%s: valueChanged(%q, %q) = %v, want %v", tt.name, tt.last, tt.current, got, tt.want)
+		}
+	}
+}
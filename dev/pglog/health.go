@@ -0,0 +1,60 @@
+package function
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ── Health Check ────────────────────────────────────────────────────
+// GET /health — pings the cache, Postgres and the S3 config bucket.
+// Returns 200 when every dependency is reachable, 503 otherwise, so
+// orchestrators can pull an instance before it starts dropping data.
+// The S3 check is skipped when S3_BUCKET is unset. The state of the
+// Postgres circuit breaker is reported as "circuit" and the connection
+// pool's usage as "pool"; neither affects the status.
+
+const healthTimeout = 5 * time.Second
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	hctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	checks := make(map[string]interface{})
+
+	check := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			status = http.StatusServiceUnavailable
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	check("cache", cache.Ping(hctx).Err())
+	check("postgres", db.Ping(hctx))
+	checks["circuit"] = dbBreaker.currentState()
+
+	stat := db.Stat()
+	checks["pool"] = map[string]int32{
+		"acquired": stat.AcquiredConns(),
+		"idle":     stat.IdleConns(),
+		"total":    stat.TotalConns(),
+		"max":      stat.MaxConns(),
+	}
+
+	if bucket := envOrDefault("S3_BUCKET", ""); bucket != "" {
+		_, err := s3Client.HeadBucket(hctx, &s3.HeadBucketInput{
+			Bucket: aws.String(bucket),
+		})
+		check("s3", err)
+	} else {
+		checks["s3"] = "skipped"
+	}
+
+	writeJSON(w, status, checks)
+}
@@ -0,0 +1,46 @@
+package function
+
+import "context"
+
+// ── Hold Last Value ─────────────────────────────────────────────────
+// A topic that momentarily reads empty (a cache miss while the writer
+// replaces the key) is never a change, but would still be stored as NULL
+// in the row's values. With "hold_last_value": true its last logged value
+// (or uns:prev with change_source "prev") is used instead, so every row
+// stays complete. Held tags are listed as "held" in the response; they
+// are still reported as missing.
+
+// holdLastValues fills empty readings from the last snapshot and returns
+// the tags that were held.
+func holdLastValues(ctx context.Context, config *pglogConfig, snapshot map[string]*topicSnapshot) []string {
+	lastSnapshotMu.Lock()
+	defer lastSnapshotMu.Unlock()
+
+	usePrev := config.ChangeSource == changeSourcePrev
+	if !usePrev {
+		loadLastSnapshot(ctx, config)
+	}
+
+	var held []string
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		if snap == nil {
+			snap = &topicSnapshot{}
+			snapshot[topic] = snap
+		}
+		if snap.Current != "" {
+			continue
+		}
+
+		last := lastSnapshot[config.stateKey(topic)]
+		if usePrev {
+			last = snap.Previous
+		}
+		if last != "" {
+			snap.Current = last
+			held = append(held, config.parseTopic(topic).Tag)
+		}
+	}
+
+	return held
+}
@@ -0,0 +1,196 @@
+package function
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ── Idempotency ─────────────────────────────────────────────────────
+// Cloud Run and Pub/Sub retry on timeouts, so a request whose insert went
+// through but whose response was lost arrives again. With IDEMPOTENCY_TTL
+// set (Go duration, e.g. "10m"), the result of each request is kept in the
+// cache for that long:
+//
+//	{prefix}:idem:{config}:{key}  →  {"status": 200, "body": {...}}
+//
+// where {prefix} is the config's cache prefix (see namespace.go) and
+// {config} its stateID, and a request whose key was already seen gets the
+// stored result, with "replayed": true, without logging anything.
+//
+// A request claims its key with SET NX before running, storing an
+// in-flight marker ({"status": 0}) for idempotencyClaimTTL, so a retry
+// arriving meanwhile, on any replica, gets 409 IDEMPOTENCY_IN_FLIGHT
+// instead of logging the same rows. A failed request releases its claim
+// so the retry runs.
+//
+// The key is the Idempotency-Key header (the event ID for CloudEvents).
+// Without one, it is derived from the line, the changed tags and a hash
+// of the values and prev values, which catches retries that land on a
+// replica with an older last snapshot. A derived key can't tell a retry
+// from the same transition happening again (a tag toggling 0 → 1 → 0 → 1),
+// so keep the TTL below how often that can happen.
+//
+// Dry runs and batched requests (?batch=N) aren't stored. Cache errors are
+// logged and the request runs as if the key were new.
+
+const (
+	maxIdempotencyKeyLength = 255
+
+	// Bounds how long a crashed request blocks retries of its key
+	idempotencyClaimTTL = time.Minute
+)
+
+// idempotencyInFlight is stored under a claimed key until the result is.
+var idempotencyInFlight = []byte(`{"status":0}`)
+
+var idempotencyTTL time.Duration
+
+type idempotentResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+func initIdempotency() {
+	raw := envOrDefault("IDEMPOTENCY_TTL", "")
+	if raw == "" {
+		return
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl < 0 {
+		fatal("Invalid IDEMPOTENCY_TTL", "value", raw)
+	}
+	idempotencyTTL = ttl
+	if ttl > 0 {
+		logger.Info("Idempotency keys enabled", "ttl", ttl.String())
+	}
+}
+
+// runLogOnce runs the pipeline unless the request's idempotency key was
+// already seen, in which case the stored result is returned.
+func runLogOnce(ctx context.Context, opts logOptions) (int, interface{}) {
+	if idempotencyTTL <= 0 || opts.IdempotencyKey == "" || opts.DryRun || opts.Batch > 1 {
+		return runLog(ctx, opts)
+	}
+
+	// The key lives under the config's cache prefix; a config that
+	// doesn't load fails in runLog
+	config := opts.BodyConfig
+	if config == nil {
+		s3Ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+		loaded, err := loadConfig(s3Ctx, opts.Config)
+		cancel()
+		if err != nil {
+			return runLog(ctx, opts)
+		}
+		config = loaded
+	}
+
+	key := idempotencyKey(config, opts.IdempotencyKey)
+	if status, body, ok := claimIdempotent(ctx, key); ok {
+		return status, body
+	}
+	status, body := runLog(ctx, opts)
+	return rememberResult(ctx, key, status, body)
+}
+
+// idempotencyKey returns the cache key of an idempotency key.
+func idempotencyKey(config *pglogConfig, key string) string {
+	return config.cachePrefix() + ":idem:" + config.stateID() + ":" + key
+}
+
+// derivedIdempotencyKey identifies the change the rows log.
+func derivedIdempotencyKey(config *pglogConfig, rows []logRow) string {
+	type rowIdentity struct {
+		Changed    []string               `json:"changed"`
+		Values     map[string]interface{} `json:"values"`
+		PrevValues map[string]interface{} `json:"prev_values"`
+	}
+	identity := make([]rowIdentity, len(rows))
+	for i, row := range rows {
+		identity[i] = rowIdentity{row.Changed, row.Values, row.PrevValues}
+	}
+
+	// Map keys are marshalled sorted, so equal rows hash equally
+	raw, _ := json.Marshal(identity)
+	sum := sha256.Sum256(raw)
+	return config.Table + ":" + config.lineKey(rows[0].UNS) + ":" + hex.EncodeToString(sum[:16])
+}
+
+// claimIdempotent claims the cache key for this request. If another
+// request holds it, it returns that request's stored result, or a 409
+// while it is still running, and true.
+func claimIdempotent(ctx context.Context, key string) (int, interface{}, bool) {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	claimed, err := cache.SetNX(ctx, key, idempotencyInFlight, min(idempotencyTTL, idempotencyClaimTTL)).Result()
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to claim idempotency key", "key", key, "error", err)
+		return 0, nil, false
+	}
+	if claimed {
+		return 0, nil, false
+	}
+
+	// A key expiring between SET NX and GET runs unclaimed
+	raw, err := cache.Get(ctx, key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.WarnContext(ctx, "Failed to read idempotency key", "key", key, "error", err)
+		}
+		return 0, nil, false
+	}
+
+	var stored idempotentResult
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &stored); err != nil || json.Unmarshal(stored.Body, &body) != nil {
+		logger.WarnContext(ctx, "Ignoring malformed idempotency record", "key", key)
+		return 0, nil, false
+	}
+	if stored.Status == 0 {
+		status, body := apiError(codeInFlight, http.StatusConflict, nil)
+		return status, body, true
+	}
+	body["replayed"] = true
+	return stored.Status, body, true
+}
+
+// rememberResult stores a successful result under the claimed key, or
+// releases the claim of a failed one (a no-op for an empty key), and
+// passes it through.
+func rememberResult(ctx context.Context, key string, status int, body interface{}) (int, interface{}) {
+	if key == "" || idempotencyTTL <= 0 {
+		return status, body
+	}
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheTimeout)
+		defer cancel()
+		if err := cache.Del(ctx, key).Err(); err != nil {
+			logger.WarnContext(ctx, "Failed to release idempotency key", "key", key, "error", err)
+		}
+		return status, body
+	}
+
+	raw, err := json.Marshal(body)
+	if err == nil {
+		raw, err = json.Marshal(idempotentResult{Status: status, Body: raw})
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to marshal idempotency record", "key", key, "error", err)
+		return status, body
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheTimeout)
+	defer cancel()
+	if err := cache.Set(ctx, key, raw, idempotencyTTL).Err(); err != nil {
+		logger.WarnContext(ctx, "Failed to store idempotency key", "key", key, "error", err)
+	}
+	return status, body
+}
@@ -0,0 +1,58 @@
+package function
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	const topic = `"topics": ["v1.0/acme/plant1/press/line1/temp"]`
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{`{"table": "uns_log", ` + topic + `}`, "uns:idem:uns_log:7f3c9a"},
+		{`{"name": "line1", "table": "uns_log", ` + topic + `}`, "uns:idem:line1:7f3c9a"},
+		{`{"name": "line1", "cache_key_prefix": "plant2", ` + topic + `}`, "plant2:idem:line1:7f3c9a"},
+	}
+	for _, tt := range tests {
+		if got := idempotencyKey(testConfig(t, tt.config), "7f3c9a"); got != tt.want {
+			t.Errorf("%s: idempotencyKey = %s, want %s", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestClaimIdempotent(t *testing.T) {
+	testCache(t)
+	old := idempotencyTTL
+	idempotencyTTL = time.Minute
+	t.Cleanup(func() { idempotencyTTL = old })
+
+	ctx := context.Background()
+	key := "pglogtest:idem:claim:" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	t.Cleanup(func() { cache.Del(context.Background(), key) })
+
+	if _, _, ok := claimIdempotent(ctx, key); ok {
+		t.Fatal("first claim of a new key didn't run")
+	}
+	status, _, ok := claimIdempotent(ctx, key)
+	if !ok || status != http.StatusConflict {
+		t.Errorf("claim while in flight = %d %v, want %d", status, ok, http.StatusConflict)
+	}
+
+	// A failed request releases the key for its retry
+	rememberResult(ctx, key, http.StatusInternalServerError, map[string]interface{}{})
+	if _, _, ok := claimIdempotent(ctx, key); ok {
+		t.Error("key still claimed after a failed request")
+	}
+
+	rememberResult(ctx, key, http.StatusOK, map[string]interface{}{"logged": true})
+	status, body, ok := claimIdempotent(ctx, key)
+	resp, _ := body.(map[string]interface{})
+	if !ok || status != http.StatusOK || resp["logged"] != true || resp["replayed"] != true {
+		t.Errorf("claim after success = %d %v %v, want the stored result", status, body, ok)
+	}
+}
@@ -0,0 +1,145 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ── Index Definitions ───────────────────────────────────────────────
+// "time_index" picks the index ensureTable creates on logged_at:
+//
+//	"btree" (default)  idx_{table}_time
+//	"brin"             idx_{table}_time_brin, far smaller for append-only
+//	                   tables queried by time range
+//	"none"             no logged_at index
+//
+// Further indexes are declared in "indexes":
+//
+//	"indexes": [
+//	  { "columns": ["tag", "logged_at"] },
+//	  { "name": "idx_uns_log_values", "columns": ["values"], "method": "gin" }
+//	]
+//
+// Columns are built-in, UNS level or typed columns; the name defaults to
+// idx_{table}_{columns}. Indexes are created IF NOT EXISTS, so changing
+// time_index adds the new index without dropping the old one.
+
+const (
+	timeIndexBtree = "btree"
+	timeIndexBrin  = "brin"
+	timeIndexNone  = "none"
+)
+
+type indexDef struct {
+	Name    string   `json:"name,omitempty"`
+	Columns []string `json:"columns"`
+	Method  string   `json:"method,omitempty"`
+}
+
+// indexMethods are the index access methods an index may use.
+var indexMethods = map[string]bool{"btree": true, "brin": true, "gin": true, "hash": true}
+
+func validateIndexes(config *pglogConfig) error {
+	switch config.TimeIndex {
+	case timeIndexBtree, timeIndexBrin, timeIndexNone:
+	default:
+		return fmt.Errorf("time_index must be %q, %q or %q", timeIndexBtree, timeIndexBrin, timeIndexNone)
+	}
+
+	known := make(map[string]bool)
+	for column := range reservedColumns {
+		known[column] = true
+	}
+	for _, level := range config.levelColumns() {
+		known[strings.ToLower(level)] = true
+	}
+	for _, col := range config.Columns {
+		known[strings.ToLower(col.Column)] = true
+	}
+
+	names := make(map[string]bool)
+	for i, index := range config.Indexes {
+		if len(index.Columns) == 0 {
+			return fmt.Errorf("indexes[%d]: columns are required", i)
+		}
+		for _, column := range index.Columns {
+			if err := validateIdentifier(column); err != nil {
+				return fmt.Errorf("indexes[%d]: %v", i, err)
+			}
+			if !known[strings.ToLower(column)] {
+				return fmt.Errorf("indexes[%d]: unknown column %q", i, column)
+			}
+		}
+		if index.Method != "" && !indexMethods[index.Method] {
+			return fmt.Errorf("indexes[%d]: unsupported method %q (btree, brin, gin, hash)", i, index.Method)
+		}
+		if index.Method == "hash" && len(index.Columns) > 1 {
+			return fmt.Errorf("indexes[%d]: hash indexes take a single column", i)
+		}
+		name := config.indexName(index)
+		if err := validateIdentifier(name); err != nil {
+			return fmt.Errorf("indexes[%d]: %v", i, err)
+		}
+		if len(name) > 63 {
+			return fmt.Errorf("indexes[%d]: name %q is longer than 63 characters", i, name)
+		}
+		if names[name] {
+			return fmt.Errorf("indexes[%d]: duplicate index name %q", i, name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// indexName returns the declared name of an index, or
+// idx_{table}_{columns}.
+func (c *pglogConfig) indexName(index indexDef) string {
+	if index.Name != "" {
+		return index.Name
+	}
+	return "idx_" + c.Table + "_" + strings.Join(index.Columns, "_")
+}
+
+// timeIndexDDL returns the statement creating the logged_at index, empty
+// for time_index "none".
+func timeIndexDDL(config *pglogConfig) string {
+	switch config.TimeIndex {
+	case timeIndexNone:
+		return ""
+	case timeIndexBrin:
+		return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING brin (logged_at);",
+			quoteIdent("idx_"+config.Table+"_time_brin"), quoteIdent(config.Table))
+	default:
+		return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (logged_at);",
+			quoteIdent("idx_"+config.Table+"_time"), quoteIdent(config.Table))
+	}
+}
+
+// ensureIndexes creates the declared indexes. It runs after the columns
+// they may refer to have been added.
+func ensureIndexes(ctx context.Context, config *pglogConfig) error {
+	if len(config.Indexes) == 0 {
+		return nil
+	}
+	pool, err := dbFor(config)
+	if err != nil {
+		return err
+	}
+	for _, index := range config.Indexes {
+		columns := make([]string, len(index.Columns))
+		for i, column := range index.Columns {
+			columns[i] = quoteIdent(column)
+		}
+		method := index.Method
+		if method == "" {
+			method = "btree"
+		}
+		_, err := pool.Exec(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING %s (%s)",
+			quoteIdent(config.indexName(index)), quoteIdent(config.Table), method, strings.Join(columns, ", ")))
+		if err != nil {
+			return fmt.Errorf("failed to create index %s: %w", config.indexName(index), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ── JSON Map Cache Layout ───────────────────────────────────────────
+// Some writers publish a whole line's state as one JSON object in a
+// string key:
+//
+//	uns:line:line1  →  {"temperature": 72, "pressure": 3.1}
+//
+// and are read with:
+//
+//	"cache_layout": "json_map",
+//	"map_key": "line:{line}"
+//
+// map_key works like hash_key (relative to the key prefix, with UNS level
+// placeholders). Each distinct key is read with one GET and each topic's
+// value is the member named after its tag, as JSON; a missing or null
+// member is a missing topic. Unlike a hash the members keep their JSON
+// types, so value_encoding must be "json". As with hashes there's no
+// previous value and wildcard topics can't be expanded.
+
+const cacheLayoutJSONMap = "json_map"
+
+// mapKey returns the cache key of the JSON map holding a topic's value.
+func (c *pglogConfig) mapKey(uns unsFields) string {
+	return c.cachePrefix() + ":" + expandLevels(c.MapKey, uns)
+}
+
+// readTopicsFromMap reads topic values from the members of JSON maps, one
+// GET per map. Previous is always empty.
+func readTopicsFromMap(ctx context.Context, config *pglogConfig) (map[string]*topicSnapshot, error) {
+	var keys []string
+	topics := make(map[string][]string)
+	for _, topic := range config.Topics {
+		key := config.mapKey(config.parseTopic(topic))
+		if _, ok := topics[key]; !ok {
+			keys = append(keys, key)
+		}
+		topics[key] = append(topics[key], topic)
+	}
+
+	pipe := cache.Pipeline()
+	for _, key := range keys {
+		pipe.Get(ctx, key)
+	}
+
+	results, err := pipe.Exec(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err := pipelineError(results, err); err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]*topicSnapshot, len(config.Topics))
+	for i, key := range keys {
+		var raw string
+		if i < len(results) {
+			raw, _ = results[i].(*redis.StringCmd).Result()
+		}
+
+		var members map[string]json.RawMessage
+		if raw != "" {
+			if err := json.Unmarshal([]byte(raw), &members); err != nil {
+				return nil, fmt.Errorf("%s: not a JSON object: %w", key, err)
+			}
+		}
+
+		for _, topic := range topics[key] {
+			current := ""
+			if member, ok := members[config.parseTopic(topic).Tag]; ok && string(member) != "null" {
+				current = string(member)
+			}
+			snapshot[topic] = &topicSnapshot{Current: current}
+		}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,69 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ── Kafka Sink ──────────────────────────────────────────────────────
+// With KAFKA_BROKERS set every inserted row is also produced to
+// KAFKA_TOPIC, keyed by its line (enterprise/site/area/line, or the
+// uns_schema levels), so one line's changes stay ordered in a partition.
+// The value is the same JSON as the MQTT change notification.
+//
+// Messages are written asynchronously and never fail the request; failed
+// writes are logged and counted in pglog_kafka_publish_errors_total.
+
+var kafkaWriter *kafka.Writer
+
+func initKafkaSink() {
+	brokers := envOrDefault("KAFKA_BROKERS", "")
+	if brokers == "" {
+		return
+	}
+	topic := envOrDefault("KAFKA_TOPIC", "uns-changes")
+
+	kafkaWriter = &kafka.Writer{
+		Addr:         kafka.TCP(strings.Split(brokers, ",")...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 50 * time.Millisecond,
+		Async:        true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				metricKafkaErrors.Add(float64(len(messages)))
+				logger.Warn("Failed to produce change to Kafka", "messages", len(messages), "error", err)
+			}
+		},
+	}
+	logger.Info("Kafka sink enabled", "brokers", brokers, "topic", topic)
+}
+
+// publishKafka queues a logged row for Kafka without blocking the request.
+func publishKafka(row logRow) {
+	if kafkaWriter == nil {
+		return
+	}
+
+	payload, err := json.Marshal(newChangeNotification(row))
+	if err != nil {
+		metricKafkaErrors.Inc()
+		logger.Warn("Failed to encode change for Kafka", "error", err)
+		return
+	}
+
+	// Async writes only fail here when the writer is closed
+	err = kafkaWriter.WriteMessages(context.Background(), kafka.Message{
+		Key:   []byte(row.Config.lineKey(row.UNS)),
+		Value: payload,
+	})
+	if err != nil {
+		metricKafkaErrors.Inc()
+		logger.Warn("Failed to produce change to Kafka", "error", err)
+	}
+}
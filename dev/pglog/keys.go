@@ -0,0 +1,96 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ── Cache Keys ──────────────────────────────────────────────────────
+// The per-topic cache keys are Go text/template strings with .Prefix
+// (CACHE_KEY_PREFIX, including the tenant, or the config's
+// cache_key_prefix) and .Topic:
+//
+//	CACHE_DATA_KEY_TEMPLATE    {{.Prefix}}:data:{{.Topic}}
+//	CACHE_PREV_KEY_TEMPLATE    {{.Prefix}}:prev:{{.Topic}}
+//	CACHE_BUFFER_KEY_TEMPLATE  {{.Prefix}}:buffer:{{.Topic}}
+//
+// e.g. CACHE_DATA_KEY_TEMPLATE='{{.Prefix}}/current/{{.Topic}}'. The
+// templates are parsed and test-rendered at startup, and a malformed one
+// (or one without exactly one {{.Topic}}) stops the function. With
+// CACHE_TENANT set, every key must also start with {{.Prefix}}.
+
+type keyVars struct {
+	Prefix string
+	Topic  string
+}
+
+// keyKinds are the per-topic keys with a configurable template.
+var keyKinds = []string{"data", "prev", "buffer"}
+
+var keyTemplates = make(map[string]*template.Template)
+
+// topicProbe is the topic used to test-render the templates.
+const topicProbe = "v1.0/probe/topic"
+
+func initKeyTemplates() {
+	for _, kind := range keyKinds {
+		env := "CACHE_" + strings.ToUpper(kind) + "_KEY_TEMPLATE"
+		raw := envOrDefault(env, fmt.Sprintf("{{.Prefix}}:%s:{{.Topic}}", kind))
+		tmpl, err := parseKeyTemplate(kind, raw)
+		if err != nil {
+			fatal("Invalid "+env, "template", raw, "error", err)
+		}
+		keyTemplates[kind] = tmpl
+	}
+}
+
+func parseKeyTemplate(kind, raw string) (*template.Template, error) {
+	tmpl, err := template.New(kind).Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var key strings.Builder
+	if err := tmpl.Execute(&key, keyVars{Prefix: keyPrefix, Topic: topicProbe}); err != nil {
+		return nil, err
+	}
+	if strings.Count(key.String(), topicProbe) != 1 {
+		return nil, errors.New("template must contain {{.Topic}} exactly once")
+	}
+
+	// The character after the prefix must not extend the tenant ID, or
+	// "{{.Prefix}}x:..." would read another tenant's keys
+	if tenantID != "" {
+		rest, ok := strings.CutPrefix(key.String(), keyPrefix)
+		if !ok || rest == "" || tenantPattern.MatchString(rest[:1]) {
+			return nil, errors.New("template must start with {{.Prefix}} and a separator when CACHE_TENANT is set")
+		}
+	}
+	return tmpl, nil
+}
+
+// cacheKey builds the cache key for a topic, e.g. uns:data:{topic}.
+func cacheKey(kind, topic string) string {
+	return renderKey(keyPrefix, kind, topic)
+}
+
+// cacheKey builds the cache key for a topic under the config's prefix,
+// see namespace.go.
+func (c *pglogConfig) cacheKey(kind, topic string) string {
+	return renderKey(c.cachePrefix(), kind, topic)
+}
+
+func renderKey(prefix, kind, topic string) string {
+	var key strings.Builder
+	// Templates are test-rendered in initKeyTemplates, so this can't fail
+	_ = keyTemplates[kind].Execute(&key, keyVars{Prefix: prefix, Topic: topic})
+	return key.String()
+}
+
+// keyAffixes returns the parts of a kind's keys before and after the topic.
+func (c *pglogConfig) keyAffixes(kind string) (before, after string) {
+	before, after, _ = strings.Cut(c.cacheKey(kind, topicProbe), topicProbe)
+	return before, after
+}
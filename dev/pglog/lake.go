@@ -0,0 +1,316 @@
+package function
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ── Parquet Lake Sink ───────────────────────────────────────────────
+// "sink" chooses where logged rows go: "postgres" (default), "parquet_s3"
+// instead of Postgres, or "both". Lake rows are buffered in memory per
+// table and written as Parquet files to
+//
+//	s3://{LAKE_BUCKET}/{LAKE_PREFIX}/{table}/{date}/{uuid}.parquet
+//
+// once LAKE_FLUSH_ROWS rows are buffered or the oldest has waited
+// LAKE_FLUSH_SECONDS, and on shutdown. {date} is the UTC date of the
+// rows' logged_at, so a flush writes one file per date it holds rows of.
+// Every file has the same schema (see lakeRow): the UNS levels as a map,
+// the values as a JSON column.
+//
+// With "both" a row is buffered once its insert succeeded. With
+// "parquet_s3" nothing is written to Postgres, so the features that need
+// tables (capture_errors, maintain_current, normalize_points, retention,
+// aggregates, the stream layout) aren't available, and rows aren't
+// published as change notifications. A failed upload keeps its rows
+// buffered for the next flush, up to lakeMaxBuffered rows per table;
+// failures are counted in pglog_lake_flush_errors_total. Backfilled rows
+// aren't written to the lake.
+
+const (
+	sinkPostgres  = "postgres"
+	sinkParquetS3 = "parquet_s3"
+	sinkBoth      = "both"
+)
+
+var (
+	lakeBucket        = envOrDefault("LAKE_BUCKET", envOrDefault("S3_BUCKET", ""))
+	lakePrefix        = strings.Trim(envOrDefault("LAKE_PREFIX", "lake"), "/")
+	lakeFlushRows     = max(1, envIntOrDefault("LAKE_FLUSH_ROWS", 10000))
+	lakeFlushInterval = time.Duration(max(1, envIntOrDefault("LAKE_FLUSH_SECONDS", 60))) * time.Second
+	lakeMaxBuffered   = 10 * lakeFlushRows
+)
+
+// lakeRow is the schema of the Parquet files.
+type lakeRow struct {
+	LoggedAt time.Time         `parquet:"logged_at,timestamp(millisecond)"`
+	Tenant   string            `parquet:"tenant"`
+	UNS      map[string]string `parquet:"uns"`
+	Tag      *string           `parquet:"tag,optional"`
+	Changed  []string          `parquet:"changed,list"`
+	Values   string            `parquet:"values,json"`
+}
+
+type lakeBuffer struct {
+	rows    []lakeRow
+	started time.Time
+}
+
+var (
+	lakeMu       sync.Mutex
+	lakeBuffers  = make(map[string]*lakeBuffer) // table → rows waiting for upload
+	lakeLoopOnce sync.Once
+)
+
+func validateSink(config *pglogConfig) error {
+	switch config.Sink {
+	case sinkPostgres:
+		return nil
+	case sinkParquetS3, sinkBoth:
+	default:
+		return fmt.Errorf("sink must be %q, %q or %q", sinkPostgres, sinkParquetS3, sinkBoth)
+	}
+	if lakeBucket == "" {
+		return fmt.Errorf("sink %q needs LAKE_BUCKET or S3_BUCKET", config.Sink)
+	}
+	if config.Sink == sinkBoth {
+		return nil
+	}
+
+	var conflicts []string
+	if config.CaptureErrors {
+		conflicts = append(conflicts, "capture_errors")
+	}
+	if config.MaintainCurrent {
+		conflicts = append(conflicts, "maintain_current")
+	}
+	if config.NormalizePoints {
+		conflicts = append(conflicts, "normalize_points")
+	}
+	if config.RetentionDays > 0 {
+		conflicts = append(conflicts, "retention_days")
+	}
+	if config.Aggregate != nil {
+		conflicts = append(conflicts, "aggregate")
+	}
+	if config.CacheLayout == cacheLayoutStream {
+		conflicts = append(conflicts, "cache_layout "+cacheLayoutStream)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("sink %q can't be combined with %s", sinkParquetS3, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// writesPostgres reports whether logged rows are inserted into Postgres.
+func (c *pglogConfig) writesPostgres() bool {
+	return c.Sink != sinkParquetS3
+}
+
+// writesLake reports whether logged rows are written to the lake.
+func (c *pglogConfig) writesLake() bool {
+	return c.Sink == sinkParquetS3 || c.Sink == sinkBoth
+}
+
+func newLakeRow(row logRow) (lakeRow, error) {
+	values, err := json.Marshal(row.Values)
+	if err != nil {
+		return lakeRow{}, fmt.Errorf("failed to marshal values: %w", err)
+	}
+	loggedAt := row.LoggedAt
+	if loggedAt.IsZero() {
+		loggedAt = time.Now() // batched rows don't read logged_at back
+	}
+
+	lr := lakeRow{
+		LoggedAt: loggedAt.UTC(),
+		Tenant:   tenantID,
+		UNS:      row.UNS.Levels,
+		Changed:  row.Changed,
+		Values:   string(values),
+	}
+	if tag, ok := row.tagColumn().(string); ok {
+		lr.Tag = &tag
+	}
+	return lr, nil
+}
+
+// bufferLake queues a logged row for the next Parquet file of its table.
+func bufferLake(row logRow) {
+	if !row.Config.writesLake() {
+		return
+	}
+	lr, err := newLakeRow(row)
+	if err != nil {
+		metricLakeErrors.Inc()
+		logger.Warn("Failed to encode row for the lake", "table", row.Config.Table, "error", err)
+		return
+	}
+
+	table := row.Config.Table
+	lakeMu.Lock()
+	buf := lakeBuffers[table]
+	if buf == nil {
+		buf = &lakeBuffer{started: time.Now()}
+		lakeBuffers[table] = buf
+	}
+	buf.rows = append(buf.rows, lr)
+	full := len(buf.rows) >= lakeFlushRows
+	lakeMu.Unlock()
+
+	lakeLoopOnce.Do(func() { go lakeFlushLoop() })
+	if full {
+		go flushLake(context.Background(), table)
+	}
+}
+
+// lakeFlushLoop flushes the buffers whose oldest row has waited
+// lakeFlushInterval.
+func lakeFlushLoop() {
+	ticker := time.NewTicker(max(time.Second, lakeFlushInterval/4))
+	defer ticker.Stop()
+	for range ticker.C {
+		var due []string
+		lakeMu.Lock()
+		for table, buf := range lakeBuffers {
+			if time.Since(buf.started) >= lakeFlushInterval {
+				due = append(due, table)
+			}
+		}
+		lakeMu.Unlock()
+
+		for _, table := range due {
+			flushLake(context.Background(), table)
+		}
+	}
+}
+
+// flushLake writes the rows buffered for a table to one Parquet file per
+// logged_at date. Rows whose file failed are put back in front of the
+// rows buffered meanwhile.
+func flushLake(ctx context.Context, table string) error {
+	lakeMu.Lock()
+	buf := lakeBuffers[table]
+	delete(lakeBuffers, table)
+	lakeMu.Unlock()
+	if buf == nil || len(buf.rows) == 0 {
+		return nil
+	}
+
+	var failed []lakeRow
+	var errs []error
+	for _, group := range groupLakeRows(buf.rows) {
+		key, err := uploadLake(ctx, table, group.date, group.rows)
+		if err != nil {
+			failed = append(failed, group.rows...)
+			errs = append(errs, err)
+			continue
+		}
+		logger.InfoContext(ctx, "Flushed rows to the lake", "table", table, "rows", len(group.rows), "key", key)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	err := errors.Join(errs...)
+	buf.rows = failed
+
+	metricLakeErrors.Inc()
+	logger.WarnContext(ctx, "Failed to flush rows to the lake", "table", table, "rows", len(buf.rows), "error", err)
+
+	lakeMu.Lock()
+	defer lakeMu.Unlock()
+	if cur := lakeBuffers[table]; cur != nil {
+		buf.rows = append(buf.rows, cur.rows...)
+	}
+	if dropped := len(buf.rows) - lakeMaxBuffered; dropped > 0 {
+		logger.ErrorContext(ctx, "Lake buffer full, dropping oldest rows", "table", table, "dropped", dropped)
+		buf.rows = buf.rows[dropped:]
+	}
+	lakeBuffers[table] = buf
+	return err
+}
+
+// flushAllLake flushes every table's buffer, on shutdown.
+func flushAllLake(ctx context.Context) error {
+	lakeMu.Lock()
+	tables := make([]string, 0, len(lakeBuffers))
+	for table := range lakeBuffers {
+		tables = append(tables, table)
+	}
+	lakeMu.Unlock()
+
+	var errs []error
+	for _, table := range tables {
+		if err := flushLake(ctx, table); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lakeGroup is the rows of one lake file.
+type lakeGroup struct {
+	date string
+	rows []lakeRow
+}
+
+// groupLakeRows splits rows by the UTC date of their logged_at, in date
+// order, keeping the buffered order within a date.
+func groupLakeRows(rows []lakeRow) []lakeGroup {
+	var groups []lakeGroup
+	index := make(map[string]int)
+	for _, row := range rows {
+		date := row.LoggedAt.UTC().Format("2006-01-02")
+		i, ok := index[date]
+		if !ok {
+			i = len(groups)
+			index[date] = i
+			groups = append(groups, lakeGroup{date: date})
+		}
+		groups[i].rows = append(groups[i].rows, row)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].date < groups[j].date })
+	return groups
+}
+
+// uploadLake encodes the rows as Parquet and uploads them under their
+// date, returning the object key.
+func uploadLake(ctx context.Context, table, date string, rows []lakeRow) (string, error) {
+	var body bytes.Buffer
+	if err := parquet.Write(&body, rows); err != nil {
+		return "", fmt.Errorf("failed to encode parquet: %w", err)
+	}
+
+	key := fmt.Sprintf("%s/%s/%s/%s.parquet", lakePrefix, table, date, newUUID())
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(lakeBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body.Bytes()),
+		ContentType: aws.String("application/vnd.apache.parquet"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to write s3://%s/%s: %w", lakeBucket, key, err)
+	}
+	return key, nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
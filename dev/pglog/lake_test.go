@@ -0,0 +1,46 @@
+package function
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGroupLakeRows(t *testing.T) {
+	at := func(s string) lakeRow {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lakeRow{LoggedAt: ts, Values: s}
+	}
+	rows := []lakeRow{
+		at("2026-02-21T00:00:05Z"),
+		at("2026-02-20T23:59:58Z"),
+		at("2026-02-21T00:00:09+01:00"), // 2026-02-20 in UTC
+		at("2026-02-21T12:00:00Z"),
+		at("2026-01-15T08:00:00Z"), // event time
+	}
+
+	groups := groupLakeRows(rows)
+	want := []struct {
+		date   string
+		values []string
+	}{
+		{"2026-01-15", []string{"2026-01-15T08:00:00Z"}},
+		{"2026-02-20", []string{"2026-02-20T23:59:58Z", "2026-02-21T00:00:09+01:00"}},
+		{"2026-02-21", []string{"2026-02-21T00:00:05Z", "2026-02-21T12:00:00Z"}},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i, w := range want {
+		var got []string
+		for _, row := range groups[i].rows {
+			got = append(got, row.Values)
+		}
+		if groups[i].date != w.date || !slices.Equal(got, w.values) {
+			t.Errorf("group %d = %s %v, want %s %v", i, groups[i].date, got, w.date, w.values)
+		}
+	}
+}
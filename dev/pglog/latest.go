@@ -0,0 +1,131 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ── Latest Rows ─────────────────────────────────────────────────────
+// GET /pglog/latest?enterprise=acme&site=factory1&area=mixing&line=line1
+//
+// Returns the most recently logged row matching the UNS level filters
+// (any level of the uns_schema may be given). ?limit=N returns the last
+// N rows as an array instead. Rows of other tenants are never returned.
+
+const maxLatestLimit = 1000
+
+type latestRow struct {
+	LoggedAt time.Time       `json:"logged_at"`
+	Tag      *string         `json:"tag"` // NULL with trigger_tag_strategy "none"
+	Values   json.RawMessage `json:"values"`
+	Changed  []string        `json:"changed"`
+}
+
+func latestHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 1
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxLatestLimit {
+			writeError(w, codeBadRequest, http.StatusBadRequest,
+				fmt.Errorf("limit must be 1-%d", maxLatestLimit))
+			return
+		}
+		limit = n
+	}
+
+	s3Ctx, cancel := context.WithTimeout(r.Context(), s3Timeout)
+	config, err := loadConfig(s3Ctx, r.URL.Query().Get("config"))
+	cancel()
+	if err != nil {
+		status, body := configError(err)
+		writeJSON(w, status, body)
+		return
+	}
+
+	// Filter on the declared UNS levels only; column names never come
+	// from the request.
+	conditions := []string{"tenant = $1"}
+	args := []interface{}{tenantID}
+	for _, level := range config.levelColumns() {
+		if value := query.Get(level); value != "" {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", quoteIdent(level), len(args)))
+		}
+	}
+	args = append(args, limit)
+
+	sql := fmt.Sprintf(`
+		SELECT logged_at, tag, values, changed
+		FROM %s
+		WHERE %s
+		ORDER BY logged_at DESC
+		LIMIT $%d
+	`, quoteIdent(config.Table), strings.Join(conditions, " AND "), len(args))
+
+	dbCtx, cancel := context.WithTimeout(r.Context(), dbTimeout)
+	defer cancel()
+
+	pool, err := dbFor(config)
+	if err != nil {
+		writeError(w, codeQuery, http.StatusInternalServerError, err)
+		return
+	}
+
+	var rows []latestRow
+	err = withReconnect(dbCtx, pool, func() error {
+		var err error
+		rows, err = queryLatest(dbCtx, pool, sql, args)
+		return err
+	})
+	if err != nil {
+		writeError(w, codeQuery, http.StatusInternalServerError, err)
+		return
+	}
+
+	if query.Has("limit") {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"table": config.Table,
+			"rows":  rows,
+		})
+		return
+	}
+
+	if len(rows) == 0 {
+		writeError(w, codeNotFound, http.StatusNotFound, errors.New("no rows found"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"table": config.Table,
+		"row":   rows[0],
+	})
+}
+
+func queryLatest(ctx context.Context, pool *pgxpool.Pool, sql string, args []interface{}) ([]latestRow, error) {
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []latestRow{}
+	for rows.Next() {
+		var row latestRow
+		if err := rows.Scan(&row.LoggedAt, &row.Tag, &row.Values, &row.Changed); err != nil {
+			return nil, err
+		}
+		row.LoggedAt = row.LoggedAt.In(displayLocation)
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,49 @@
+package function
+
+import (
+	"context"
+	"sync"
+)
+
+// ── Line Locks ──────────────────────────────────────────────────────
+// Change detection compares the cache against the last snapshot, and the
+// snapshot only moves after the insert. Two overlapping invocations for
+// the same line would both see the old snapshot and log the same change
+// twice, so the read → detect → insert → update sequence holds a per-line
+// lock. An invocation that had to wait and then finds nothing left to log
+// reports "deduplicated": true. Waiting ends with the request context, so
+// a line stuck behind a hung invocation doesn't pile up requests.
+//
+// Locks live in process memory, so invocations on different replicas
+// aren't serialised.
+
+var (
+	lineLocksMu sync.Mutex
+	lineLocks   = make(map[string]chan struct{}) // line → held while its slot is full
+)
+
+// lockLine locks the line and returns the unlock func, and whether
+// another invocation held the lock first. It gives up with ctx's error
+// when ctx is done first.
+func lockLine(ctx context.Context, line string) (func(), bool, error) {
+	lineLocksMu.Lock()
+	slot, ok := lineLocks[line]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		lineLocks[line] = slot
+	}
+	lineLocksMu.Unlock()
+
+	unlock := func() { <-slot }
+	select {
+	case slot <- struct{}{}:
+		return unlock, false, nil
+	default:
+	}
+	select {
+	case slot <- struct{}{}:
+		return unlock, true, nil
+	case <-ctx.Done():
+		return nil, true, ctx.Err()
+	}
+}
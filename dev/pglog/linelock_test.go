@@ -0,0 +1,89 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunLogSerialisesLine(t *testing.T) {
+	const table = "pglog_test_linelock"
+	config := testPipeline(t, table, "v1.0/acme/plant1/press/linelock/temp", "72")
+
+	// Both invocations see the value as a first change; only the one that
+	// gets the line first may log it
+	statuses := make([]int, 2)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			statuses[i], _ = runLog(context.Background(), logOptions{BodyConfig: config})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("invocation %d: status %d", i, status)
+		}
+	}
+	if n := countRows(t, table); n != 1 {
+		t.Errorf("%d rows inserted, want 1", n)
+	}
+}
+
+func TestLockLineWaited(t *testing.T) {
+	ctx := context.Background()
+	unlock, waited, _ := lockLine(ctx, "acme/factory1/waited")
+	if waited {
+		t.Error("first lock reported waiting")
+	}
+
+	started, done := make(chan struct{}), make(chan bool)
+	go func() {
+		close(started)
+		unlock, waited, _ := lockLine(ctx, "acme/factory1/waited")
+		unlock()
+		done <- waited
+	}()
+
+	// Another line isn't blocked
+	other, otherWaited, _ := lockLine(ctx, "acme/factory1/other")
+	other()
+	if otherWaited {
+		t.Error("lock of another line reported waiting")
+	}
+
+	// Give the second invocation time to find the line held
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	unlock()
+	if !<-done {
+		t.Error("second lock of a held line didn't report waiting")
+	}
+}
+
+func TestLockLineContextDone(t *testing.T) {
+	unlock, _, _ := lockLine(context.Background(), "acme/factory1/hung")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := lockLine(ctx, "acme/factory1/hung"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("lockLine on a held line = %v, want the context's deadline", err)
+	}
+
+	// Giving up leaves the line usable once its holder unlocks
+	unlock()
+	unlock, waited, err := lockLine(context.Background(), "acme/factory1/hung")
+	if err != nil || waited {
+		t.Errorf("lockLine after unlock = %v, waited %v", err, waited)
+	}
+	unlock()
+}
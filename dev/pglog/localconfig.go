@@ -0,0 +1,74 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ── Local Config ────────────────────────────────────────────────────
+// For local development and S3 outages the function's own config can come
+// from the environment instead: PGLOG_CONFIG holds the config JSON itself
+// and PGLOG_CONFIG_FILE the path of a file containing it (a .gz file is
+// decompressed). PGLOG_CONFIG wins when both are set.
+//
+// S3 stays the source of truth. The local config is used when S3_BUCKET
+// is unset, or when reading the object fails (it's re-read from S3 once
+// the TTL expires); a config S3 returns is never overridden. Only the
+// FUNCTION_TARGET config falls back, as named configs aren't in the
+// environment.
+
+// localConfigBody returns the local config JSON and where it was read
+// from. ok is false when neither variable is set.
+func localConfigBody() (body []byte, source string, ok bool, err error) {
+	if raw := os.Getenv("PGLOG_CONFIG"); raw != "" {
+		return []byte(raw), "env:PGLOG_CONFIG", true, nil
+	}
+	path := os.Getenv("PGLOG_CONFIG_FILE")
+	if path == "" {
+		return nil, "", false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("failed to read PGLOG_CONFIG_FILE: %w", err)
+	}
+	defer f.Close()
+	body, err = readConfigBody(f, "", path)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("failed to read PGLOG_CONFIG_FILE: %w", err)
+	}
+	return body, "file:" + path, true, nil
+}
+
+// loadLocalConfigSet parses the local config and caches it under key;
+// reason is logged with it. Called with configMu held. Returns nil
+// without an error when no local config is set.
+func loadLocalConfigSet(ctx context.Context, key, reason string) (*configSet, error) {
+	if key != envOrDefault("FUNCTION_TARGET", "pglog") {
+		return nil, nil
+	}
+	body, source, ok, err := localConfigBody()
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	configs, err := parseConfigs(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	for _, config := range configs {
+		if err := loadSchemas(ctx, config); err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+	}
+
+	set := &configSet{configs: configs, fetched: time.Now(), source: source, local: true}
+	cachedConfigs[key] = set
+	for _, config := range configs {
+		logger.InfoContext(ctx, "Loaded config",
+			"source", source, "reason", reason, "name", config.Name,
+			"topics", len(config.Topics), "table", config.Table)
+	}
+	return set, nil
+}
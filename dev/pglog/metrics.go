@@ -0,0 +1,69 @@
+package function
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ── Metrics ─────────────────────────────────────────────────────────
+// Prometheus metrics, served from GET /metrics. OpenMetrics is offered
+// for the exemplars of pglog_rows_inserted_total, see trace.go.
+
+var (
+	metricsHandler = promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}))
+
+	metricInvocations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_invocations_total",
+		Help: "Number of change-logging invocations.",
+	})
+
+	metricChangesDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pglog_changes_detected_total",
+		Help: "Number of changed tags detected, by line.",
+	}, []string{"line"})
+
+	metricRowsInserted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_rows_inserted_total",
+		Help: "Number of snapshot rows inserted into Postgres.",
+	})
+
+	metricInsertErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_insert_errors_total",
+		Help: "Number of failed snapshot row inserts.",
+	})
+
+	metricInsertRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_insert_retries_total",
+		Help: "Number of snapshot row inserts retried after a transient error.",
+	})
+
+	metricMirrorWriteErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_mirror_write_errors_total",
+		Help: "Number of writes that failed on one of several DATABASE_URLS.",
+	})
+
+	metricKafkaErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_kafka_publish_errors_total",
+		Help: "Number of changes that failed to be produced to Kafka.",
+	})
+
+	metricWebhookErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_webhook_errors_total",
+		Help: "Number of changes that failed to be delivered to WEBHOOK_URL.",
+	})
+
+	metricLakeErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglog_lake_flush_errors_total",
+		Help: "Number of failed attempts to write rows to the Parquet lake.",
+	})
+
+	metricHandlerDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pglog_handler_duration_seconds",
+		Help:    "Duration of change-logging invocations.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
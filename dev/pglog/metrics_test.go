@@ -0,0 +1,114 @@
+package function
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrapeMetric reads an unlabelled sample from GET /metrics.
+func scrapeMetric(t *testing.T, name string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metricsHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", rec.Code)
+	}
+
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), name+" ")
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return f
+	}
+	t.Fatalf("%s not exposed", name)
+	return 0
+}
+
+// runLogMetrics are the counters a logging run moves.
+var runLogMetrics = []string{
+	"pglog_invocations_total",
+	"pglog_rows_inserted_total",
+	"pglog_insert_errors_total",
+	"pglog_insert_retries_total",
+}
+
+// checkRunLogMetrics runs runLog and checks how far each of
+// runLogMetrics moved; unlisted ones must not move.
+func checkRunLogMetrics(t *testing.T, opts logOptions, want map[string]float64) {
+	t.Helper()
+	before := make(map[string]float64)
+	for _, name := range runLogMetrics {
+		before[name] = scrapeMetric(t, name)
+	}
+	runLog(context.Background(), opts)
+	for _, name := range runLogMetrics {
+		if got := scrapeMetric(t, name) - before[name]; got != want[name] {
+			t.Errorf("%s moved by %v, want %v", name, got, want[name])
+		}
+	}
+}
+
+func TestMetricsCacheError(t *testing.T) {
+	unreachable, err := newCacheClient("redis://127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("newCacheClient: %v", err)
+	}
+	old := cache
+	cache = unreachable
+	t.Cleanup(func() {
+		cache = old
+		unreachable.Close()
+	})
+
+	// Fails before inserting: counted as an invocation only
+	config := testConfig(t, `{"topics": ["v1.0/acme/plant1/press/line1/temp"]}`)
+	checkRunLogMetrics(t, logOptions{DryRun: true, BodyConfig: config}, map[string]float64{
+		"pglog_invocations_total": 1,
+	})
+}
+
+func TestMetricsRowInserted(t *testing.T) {
+	config := testPipeline(t, "pglog_test_metrics", "v1.0/acme/plant1/press/metrics/temp", "72")
+	checkRunLogMetrics(t, logOptions{BodyConfig: config}, map[string]float64{
+		"pglog_invocations_total":   1,
+		"pglog_rows_inserted_total": 1,
+	})
+}
+
+func TestMetricsInsertFailed(t *testing.T) {
+	const table = "pglog_test_metrics_fail"
+	config := testPipeline(t, table, "v1.0/acme/plant1/press/metricsfail/temp", "72")
+	withInsertBackoff(t, 2, time.Millisecond)
+
+	// Every insert hits a retryable serialization failure
+	ctx := context.Background()
+	_, err := db.Exec(ctx, `
+		CREATE OR REPLACE FUNCTION pglog_test_fail() RETURNS trigger LANGUAGE plpgsql AS $$
+		BEGIN
+			RAISE EXCEPTION 'could not serialize' USING ERRCODE = '40001';
+		END $$;
+		CREATE TRIGGER pglog_test_fail BEFORE INSERT ON `+quoteIdent(table)+`
+			FOR EACH ROW EXECUTE FUNCTION pglog_test_fail();`)
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(context.Background(), "DROP FUNCTION IF EXISTS pglog_test_fail() CASCADE") })
+
+	checkRunLogMetrics(t, logOptions{BodyConfig: config}, map[string]float64{
+		"pglog_invocations_total":    1,
+		"pglog_insert_errors_total":  1,
+		"pglog_insert_retries_total": 2,
+	})
+}
@@ -0,0 +1,134 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ── Schema Migration ────────────────────────────────────────────────
+// CREATE TABLE IF NOT EXISTS leaves an existing table alone, so columns
+// needed by features enabled later (new uns_schema levels, vtq, typed
+// columns, tenant, prev_values/deltas) would be missing and every insert
+// would fail. ensureTable therefore reads the table's columns from
+// information_schema.columns and adds the missing ones with
+// ALTER TABLE ... ADD COLUMN IF NOT EXISTS. Existing columns are never
+// dropped; the only change is the tag column losing NOT NULL for
+// trigger_tag_strategy "none" (see rowmode.go).
+
+type columnDef struct {
+	Name string
+	Def  string
+}
+
+// expectedColumns returns the columns beyond the original schema that the
+// config writes to. Level columns default to "unknown" (see parseTopic)
+// so they can be added to tables that already hold rows.
+func expectedColumns(config *pglogConfig) []columnDef {
+	var cols []columnDef
+	for _, level := range config.levelColumns() {
+		cols = append(cols, columnDef{level, "TEXT NOT NULL DEFAULT 'unknown'"})
+	}
+	cols = append(cols,
+		columnDef{"tenant", "TEXT NOT NULL DEFAULT ''"},
+		columnDef{"prev_values", "JSONB"},
+		columnDef{"deltas", "JSONB"},
+	)
+	if config.ValueSchema == valueSchemaVTQ {
+		cols = append(cols,
+			columnDef{"quality", "JSONB"},
+			columnDef{"source_ts", "TIMESTAMPTZ"},
+		)
+	}
+	if config.hasUnits() {
+		cols = append(cols, columnDef{"unit", "TEXT"})
+	}
+	for _, col := range config.Columns {
+		cols = append(cols, columnDef{col.Column, columnTypes[col.Type]})
+	}
+	return cols
+}
+
+func migrateColumns(ctx context.Context, config *pglogConfig) error {
+	existing, err := tableColumns(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	var ddl strings.Builder
+	var added []string
+	for _, col := range expectedColumns(config) {
+		if !existing[col.Name] {
+			fmt.Fprintf(&ddl, "ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;\n",
+				quoteIdent(config.Table), quoteIdent(col.Name), col.Def)
+			added = append(added, col.Name)
+		}
+	}
+	if len(added) > 0 {
+		pool, err := dbFor(config)
+		if err != nil {
+			return err
+		}
+		if _, err := pool.Exec(ctx, ddl.String()); err != nil {
+			return fmt.Errorf("failed to add columns %v: %w", added, err)
+		}
+		logger.InfoContext(ctx, "Migrated table", "table", config.Table, "added", added)
+	}
+
+	if config.TriggerTagStrategy == tagStrategyNone {
+		return allowNullTag(ctx, config)
+	}
+	return nil
+}
+
+// allowNullTag drops the NOT NULL of the tag column, if it still has it.
+func allowNullTag(ctx context.Context, config *pglogConfig) error {
+	pool, err := dbFor(config)
+	if err != nil {
+		return err
+	}
+	var nullable string
+	err = pool.QueryRow(ctx, `
+		SELECT is_nullable
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND column_name = 'tag'
+	`, config.Table).Scan(&nullable)
+	if err != nil {
+		return fmt.Errorf("failed to read tag column: %w", err)
+	}
+	if nullable == "YES" {
+		return nil
+	}
+	if _, err := pool.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN tag DROP NOT NULL", quoteIdent(config.Table))); err != nil {
+		return fmt.Errorf("failed to allow NULL tags: %w", err)
+	}
+	logger.InfoContext(ctx, "Migrated table", "table", config.Table, "nullable", "tag")
+	return nil
+}
+
+// tableColumns returns the column names of a table in the current schema.
+func tableColumns(ctx context.Context, config *pglogConfig) (map[string]bool, error) {
+	pool, err := dbFor(config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+	`, config.Table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
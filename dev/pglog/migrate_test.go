@@ -0,0 +1,167 @@
+package function
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpectedColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{"defaults", `{"topics": ["v1.0/a/b/c/d/temp"]}`,
+			[]string{"enterprise", "site", "area", "line", "tenant", "prev_values", "deltas"}},
+		{"vtq", `{"topics": ["v1.0/a/b/c/d/temp"], "value_schema": "vtq"}`,
+			[]string{"enterprise", "site", "area", "line", "tenant", "prev_values", "deltas", "quality", "source_ts"}},
+		{"units and typed columns", `{"topics": ["v1.0/a/b/c/d/temp"], "value_schema": "vtq", "unit_from_value": true,
+			"columns": [{"tag": "temp", "column": "temperature", "type": "double"}]}`,
+			[]string{"enterprise", "site", "area", "line", "tenant", "prev_values", "deltas", "quality", "source_ts", "unit", "temperature"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, col := range expectedColumns(testConfig(t, tt.config)) {
+			names = append(names, col.Name)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("%s: expectedColumns = %v, want %v", tt.name, names, tt.want)
+		}
+	}
+}
+
+// testDB points the default pool at PGLOG_TEST_DATABASE_URL, a database
+// the test may create and drop tables in, or skips the test.
+func testDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("PGLOG_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("PGLOG_TEST_DATABASE_URL not set")
+	}
+	pool, err := newDBPool(context.Background(), url)
+	if err != nil {
+		t.Fatalf("newDBPool: %v", err)
+	}
+	old := db
+	db = pool
+	t.Cleanup(func() {
+		db = old
+		pool.Close()
+	})
+}
+
+// testCache points the cache client at PGLOG_TEST_CACHE_URL, a Redis the
+// test may write keys to, or skips the test.
+func testCache(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("PGLOG_TEST_CACHE_URL")
+	if url == "" {
+		t.Skip("PGLOG_TEST_CACHE_URL not set")
+	}
+	client, err := newCacheClient(url)
+	if err != nil {
+		t.Fatalf("newCacheClient: %v", err)
+	}
+	old := cache
+	cache = client
+	t.Cleanup(func() {
+		cache = old
+		client.Close()
+	})
+}
+
+// testPipeline returns a config logging topic to a fresh table, with the
+// topic's cache value set to value under a key prefix of its own, for
+// tests that run runLog end to end.
+func testPipeline(t *testing.T, table, topic, value string) *pglogConfig {
+	t.Helper()
+	testDB(t)
+	testCache(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prefix := "pglogtest" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	config := testConfig(t, `{"table": "`+table+`", "cache_key_prefix": "`+prefix+`", "topics": ["`+topic+`"]}`)
+	drop := "DROP TABLE IF EXISTS " + quoteIdent(table) + " CASCADE"
+	if _, err := db.Exec(ctx, drop); err != nil {
+		t.Fatalf("drop table: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Exec(ctx, drop)
+		if keys, _ := cache.Keys(ctx, prefix+":*").Result(); len(keys) > 0 {
+			cache.Del(ctx, keys...)
+		}
+	})
+
+	if err := ensureTable(ctx, config); err != nil {
+		t.Fatalf("ensureTable: %v", err)
+	}
+	if err := cache.Set(ctx, config.cacheKey("data", topic), value, 0).Err(); err != nil {
+		t.Fatalf("set %s: %v", topic, err)
+	}
+	return config
+}
+
+// countRows returns the number of rows in a table of the test database.
+func countRows(t *testing.T, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(context.Background(), "SELECT count(*) FROM "+quoteIdent(table)).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestMigrateColumns(t *testing.T) {
+	testDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const table = "pglog_test_migrate"
+	_, err := db.Exec(ctx, `
+		DROP TABLE IF EXISTS `+table+`;
+		CREATE TABLE `+table+` (
+			id        BIGSERIAL    PRIMARY KEY,
+			logged_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+			line      TEXT         NOT NULL,
+			tag       TEXT         NOT NULL,
+			values    JSONB        NOT NULL,
+			changed   TEXT[]       NOT NULL
+		);
+		INSERT INTO `+table+` (line, tag, values, changed) VALUES ('line1', 'temp', '{}', '{}');
+	`)
+	if err != nil {
+		t.Fatalf("create old table: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(context.Background(), "DROP TABLE IF EXISTS "+table) })
+
+	config := testConfig(t, `{"table": "`+table+`", "topics": ["v1.0/a/b/c/d/temp"], "value_schema": "vtq",
+		"columns": [{"tag": "temp", "column": "temperature", "type": "double"}]}`)
+
+	// A second run finds nothing to add
+	for run := 1; run <= 2; run++ {
+		if err := migrateColumns(ctx, config); err != nil {
+			t.Fatalf("run %d: migrateColumns: %v", run, err)
+		}
+		existing, err := tableColumns(ctx, config)
+		if err != nil {
+			t.Fatalf("tableColumns: %v", err)
+		}
+		for _, col := range expectedColumns(config) {
+			if !existing[col.Name] {
+				t.Errorf("run %d: column %s missing", run, col.Name)
+			}
+		}
+	}
+
+	var enterprise string
+	if err := db.QueryRow(ctx, "SELECT enterprise FROM "+table).Scan(&enterprise); err != nil || enterprise != "unknown" {
+		t.Errorf("existing row: enterprise = %q, %v, want unknown", enterprise, err)
+	}
+}
@@ -0,0 +1,179 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ── Mirrored Writes ─────────────────────────────────────────────────
+// DATABASE_URLS (comma-separated) replaces DATABASE_URL with several
+// databases, e.g. a primary and a standby historian:
+//
+//	DATABASE_URLS=postgres://logger@pg-a:5432/uns,postgres://logger@pg-b:5432/uns
+//
+// The first is the primary: reads (/latest, /export, retention, /health)
+// use it alone. Tables are ensured and rows, current state and backfills
+// written on every database in turn, with the same logged_at. A write
+// succeeds when at least DB_MIN_WRITES (default all) databases took it;
+// the other failures are logged and counted in
+// pglog_mirror_write_errors_total. Configs with their own "database" are
+// not mirrored.
+
+var (
+	mirrorURLs  []string // DATABASE_URLS after the first
+	dbMinWrites int
+)
+
+// parseDatabaseURLs splits DATABASE_URLS, dropping empty entries.
+func parseDatabaseURLs(raw string) []string {
+	var urls []string
+	for _, u := range strings.Split(raw, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// initMirrors opens the pools of the mirror databases and reads
+// DB_MIN_WRITES.
+func initMirrors(urls []string) {
+	for _, u := range urls {
+		if err := openDatabase(&pglogConfig{Database: u}); err != nil {
+			fatal("Invalid DATABASE_URLS entry", "database", redactDatabase(u))
+		}
+	}
+	mirrorURLs = urls
+
+	dbMinWrites = envIntOrDefault("DB_MIN_WRITES", 1+len(urls))
+	if dbMinWrites < 1 || dbMinWrites > 1+len(urls) {
+		fatal("Invalid DB_MIN_WRITES", "value", dbMinWrites, "databases", 1+len(urls))
+	}
+	if len(urls) > 0 {
+		logger.Info("Mirrored writes enabled", "databases", 1+len(urls), "min_writes", dbMinWrites)
+	}
+}
+
+// writeTargets returns the config and, for configs on the primary, a copy
+// per mirror, so dbFor resolves each target's pool.
+func writeTargets(config *pglogConfig) []*pglogConfig {
+	if config.Database != "" || len(mirrorURLs) == 0 {
+		return []*pglogConfig{config}
+	}
+	targets := []*pglogConfig{config}
+	for _, u := range mirrorURLs {
+		mirror := *config
+		mirror.Database = u
+		targets = append(targets, &mirror)
+	}
+	return targets
+}
+
+// mirrored reports whether the config's writes go to several databases.
+func mirrored(config *pglogConfig) bool {
+	return config.Database == "" && len(mirrorURLs) > 0
+}
+
+// stampLoggedAt gives a row without an explicit logged_at the current
+// time when it is mirrored, so every database stores the same one.
+func stampLoggedAt(row *logRow) {
+	if row.LoggedAt.IsZero() && mirrored(row.Config) {
+		row.LoggedAt = time.Now()
+	}
+}
+
+// writeQuorum runs write against every target in turn and succeeds when
+// at least DB_MIN_WRITES of them did. Each target gets its own reconnect
+// attempt (see withReconnect), so a lost connection to one database
+// doesn't repeat the write on the others. When too few databases took
+// the write it returns a *quorumError naming the ones that did.
+func writeQuorum(ctx context.Context, config *pglogConfig, write func(target *pglogConfig) error) error {
+	return writePending(ctx, config, nil, 0, write)
+}
+
+// quorumError is a write that fewer than DB_MIN_WRITES databases took.
+// Written and Failed list the targets (see targetName), so a retry or a
+// dead letter covers only the failed ones: the others already hold the
+// row.
+type quorumError struct {
+	Written  []string
+	Failed   []string
+	Required int
+	err      error
+}
+
+func (e *quorumError) Error() string {
+	return fmt.Sprintf("%d of %d databases written, %d required: %v",
+		len(e.Written), len(e.Written)+len(e.Failed), e.Required, e.err)
+}
+
+func (e *quorumError) Unwrap() error {
+	return e.err
+}
+
+// writePending is writeQuorum for a write that written databases already
+// took: only the targets named in pending are written (all when pending
+// is nil), and the quorum counts the earlier writes.
+func writePending(ctx context.Context, config *pglogConfig, pending []string, written int, write func(target *pglogConfig) error) error {
+	writeTarget := func(target *pglogConfig) error {
+		pool, err := dbFor(target)
+		if err != nil {
+			return err
+		}
+		return withReconnect(ctx, pool, func() error { return write(target) })
+	}
+
+	targets := writeTargets(config)
+	if pending != nil {
+		targets = slices.DeleteFunc(targets, func(target *pglogConfig) bool {
+			return !slices.Contains(pending, targetName(target))
+		})
+		if len(targets) == 0 {
+			return fmt.Errorf("none of the databases %v is configured", pending)
+		}
+	}
+	if len(targets) == 1 && written == 0 {
+		return writeTarget(targets[0])
+	}
+
+	qe := &quorumError{Required: dbMinWrites - written}
+	for _, target := range targets {
+		if err := writeTarget(target); err != nil {
+			metricMirrorWriteErrors.Inc()
+			logger.WarnContext(ctx, "Database write failed", "table", config.Table,
+				"database", targetName(target), "error", err)
+			if qe.err == nil {
+				qe.err = err
+			}
+			qe.Failed = append(qe.Failed, targetName(target))
+			continue
+		}
+		qe.Written = append(qe.Written, targetName(target))
+	}
+	if len(qe.Written) < qe.Required {
+		return qe
+	}
+	return nil
+}
+
+// markPartialWrite records on the row which databases took it when err is
+// a *quorumError, so retrying or replaying the row skips them.
+func markPartialWrite(row *logRow, err error) {
+	var qe *quorumError
+	if errors.As(err, &qe) {
+		row.Pending = qe.Failed
+		row.Written += len(qe.Written)
+	}
+}
+
+// targetName identifies a write target in logs.
+func targetName(target *pglogConfig) string {
+	if target.Database == "" {
+		return "primary"
+	}
+	return redactDatabase(target.Database)
+}
@@ -0,0 +1,103 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// withMirrors mirrors the primary to the given databases, whose pools
+// are created but never dialled.
+func withMirrors(t *testing.T, minWrites int, urls ...string) {
+	t.Helper()
+	for _, u := range urls {
+		if err := openDatabase(&pglogConfig{Database: u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldURLs, oldMin := mirrorURLs, dbMinWrites
+	mirrorURLs, dbMinWrites = urls, minWrites
+	t.Cleanup(func() {
+		mirrorURLs, dbMinWrites = oldURLs, oldMin
+		dbPoolsMu.Lock()
+		defer dbPoolsMu.Unlock()
+		for _, u := range urls {
+			dbPools[u].Close()
+			delete(dbPools, u)
+		}
+	})
+}
+
+func TestWritePendingSkipsWrittenTargets(t *testing.T) {
+	const m1, m2 = "postgres://logger@pg-m1:5432/uns", "postgres://logger@pg-m2:5432/uns"
+	withMirrors(t, 3, m1, m2)
+
+	tests := []struct {
+		name        string
+		down        []string // targets whose write fails
+		wantWrites  []string
+		wantPending []string
+		wantWritten int
+		wantErr     bool
+	}{
+		{"mirror down", []string{m2}, []string{"primary", m1, m2}, []string{redactDatabase(m2)}, 2, true},
+		{"retry reaches it", nil, []string{m2}, nil, 0, false},
+	}
+
+	row := &logRow{Config: &pglogConfig{Table: "uns_log"}}
+	for _, tt := range tests {
+		var writes []string
+		err := writePending(context.Background(), row.Config, row.Pending, row.Written, func(target *pglogConfig) error {
+			name := target.Database
+			if name == "" {
+				name = "primary"
+			}
+			writes = append(writes, name)
+			if slices.Contains(tt.down, target.Database) {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+		if (err != nil) != tt.wantErr || !slices.Equal(writes, tt.wantWrites) {
+			t.Errorf("%s: err = %v, wrote %v, want error %v and %v", tt.name, err, writes, tt.wantErr, tt.wantWrites)
+		}
+
+		if err != nil {
+			markPartialWrite(row, err)
+		} else {
+			row.Pending, row.Written = nil, 0
+		}
+		if !slices.Equal(row.Pending, tt.wantPending) || row.Written != tt.wantWritten {
+			t.Errorf("%s: pending %v written %d, want %v %d", tt.name, row.Pending, row.Written, tt.wantPending, tt.wantWritten)
+		}
+	}
+}
+
+func TestWritePendingQuorum(t *testing.T) {
+	const m1 = "postgres://logger@pg-m1:5432/uns"
+	withMirrors(t, 1, m1)
+
+	var qe *quorumError
+	err := writePending(context.Background(), &pglogConfig{Table: "uns_log"}, nil, 0, func(target *pglogConfig) error {
+		if target.Database == m1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("one of two databases with DB_MIN_WRITES=1: %v", err)
+	}
+
+	err = writePending(context.Background(), &pglogConfig{Table: "uns_log"}, nil, 0, func(*pglogConfig) error {
+		return errors.New("connection refused")
+	})
+	if !errors.As(err, &qe) || len(qe.Written) != 0 || len(qe.Failed) != 2 {
+		t.Errorf("all databases down: err = %v", err)
+	}
+
+	err = writePending(context.Background(), &pglogConfig{Table: "uns_log"}, []string{"postgres://gone"}, 1, func(*pglogConfig) error { return nil })
+	if err == nil {
+		t.Error("pending database no longer configured: no error")
+	}
+}
@@ -0,0 +1,117 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ── Per-Config Namespaces ───────────────────────────────────────────
+// One deployment can serve configs living in different namespaces:
+//
+//	"cache_key_prefix": "plant2",
+//	"database": "postgres://logger@pg-plant2:5432/plant2"
+//
+// cache_key_prefix replaces CACHE_KEY_PREFIX in the config's cache keys
+// (data/prev/buffer keys, hashes, streams) and in the keys of its last
+// snapshot and idempotency records, so namespaces with the same topic
+// paths keep their own change detection. It can't be used with
+// CACHE_TENANT, whose prefix is the isolation boundary.
+//
+// database is a Postgres URL used instead of DATABASE_URL for the config's
+// tables. It must parse when the config is loaded; each distinct URL gets
+// its own pool (sized by the DB_* variables) that lives until shutdown.
+// Writes never fall back to DATABASE_URL when that pool is missing.
+// /health only checks the DATABASE_URL pool.
+
+var (
+	dbPoolsMu sync.Mutex
+	dbPools   = make(map[string]*pgxpool.Pool) // database URL → pool
+)
+
+func validateNamespace(config *pglogConfig) error {
+	if prefix := config.CacheKeyPrefix; prefix != "" {
+		if tenantID != "" {
+			return fmt.Errorf("cache_key_prefix can't be set with CACHE_TENANT")
+		}
+		if strings.ContainsAny(prefix, "*?[]\\ \t\n") {
+			return fmt.Errorf("cache_key_prefix %q contains a reserved character", prefix)
+		}
+	}
+	if config.Database != "" {
+		if _, err := pgxpool.ParseConfig(config.Database); err != nil {
+			// The parse error may quote the URL, password included
+			return fmt.Errorf("database is not a valid Postgres URL")
+		}
+	}
+	return nil
+}
+
+// redactDatabase hides the password of a database URL, for responses.
+func redactDatabase(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return "[redacted]" // key=value form
+	}
+	return u.Redacted()
+}
+
+// cachePrefix returns the prefix of the config's cache keys.
+func (c *pglogConfig) cachePrefix() string {
+	if c.CacheKeyPrefix != "" {
+		return c.CacheKeyPrefix
+	}
+	return keyPrefix
+}
+
+// openDatabase creates the pool of a config's database, unless one exists.
+// Pools connect lazily, so this doesn't wait for the server.
+func openDatabase(config *pglogConfig) error {
+	if config.Database == "" {
+		return nil
+	}
+
+	dbPoolsMu.Lock()
+	defer dbPoolsMu.Unlock()
+	if dbPools[config.Database] != nil {
+		return nil
+	}
+	pool, err := newDBPool(context.Background(), config.Database)
+	if err != nil {
+		return fmt.Errorf("failed to create pool for database: %v", err)
+	}
+	dbPools[config.Database] = pool
+	return nil
+}
+
+// dbFor returns the pool of the config's database, or the DATABASE_URL
+// pool. Pools are opened as configs load, so a missing one (after
+// shutdown) is an error rather than a write to the wrong database.
+func dbFor(config *pglogConfig) (*pgxpool.Pool, error) {
+	if config.Database == "" {
+		return db, nil
+	}
+	dbPoolsMu.Lock()
+	defer dbPoolsMu.Unlock()
+	if pool := dbPools[config.Database]; pool != nil {
+		return pool, nil
+	}
+	return nil, fmt.Errorf("no pool for database %s", redactDatabase(config.Database))
+}
+
+// closeDatabases closes the pools of config databases.
+func closeDatabases() {
+	dbPoolsMu.Lock()
+	defer dbPoolsMu.Unlock()
+	for url, pool := range dbPools {
+		pool.Close()
+		delete(dbPools, url)
+	}
+}
@@ -0,0 +1,130 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ── NDJSON Stream ───────────────────────────────────────────────────
+// GET /pglog/stream?from=2026-02-01T00:00:00Z&to=2026-02-02T00:00:00Z&line=line1
+//
+// Streams the rows logged in [from, to), filtered like /export, as
+// newline-delimited JSON in logged_at order:
+//
+//	{"logged_at":"...","uns":{"enterprise":"acme",...},"tag":"temperature","values":{...},"changed":["temperature"]}
+//
+// Rows are written as pgx reads them from Postgres and flushed every
+// streamFlushRows rows or streamFlushInterval, so arbitrarily large
+// ranges never sit in memory and clients see data promptly. The request
+// runs under EXPORT_TIMEOUT_MS. A failure after the first row can't change
+// the status any more, so it ends the stream with an {"error": ...} line.
+
+const (
+	streamFlushRows     = 500
+	streamFlushInterval = time.Second
+)
+
+type streamRow struct {
+	LoggedAt time.Time         `json:"logged_at"`
+	UNS      map[string]string `json:"uns"`
+	Tag      *string           `json:"tag"`
+	Values   json.RawMessage   `json:"values"`
+	Changed  []string          `json:"changed"`
+}
+
+func ndjsonHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, to, err := parseRange(query)
+	if err != nil {
+		writeError(w, codeBadRequest, http.StatusBadRequest, err)
+		return
+	}
+
+	s3Ctx, cancel := context.WithTimeout(r.Context(), s3Timeout)
+	config, err := loadConfig(s3Ctx, query.Get("config"))
+	cancel()
+	if err != nil {
+		status, body := configError(err)
+		writeJSON(w, status, body)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	where, args := rangeFilter(config, query, from, to)
+	levels := config.levelColumns()
+	columns := []string{"logged_at", "tag", "values", "changed"}
+	for _, level := range levels {
+		columns = append(columns, quoteIdent(level))
+	}
+
+	pool, err := dbFor(config)
+	if err != nil {
+		writeError(w, codeQuery, http.StatusInternalServerError, err)
+		return
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		ORDER BY logged_at
+	`, strings.Join(columns, ", "), quoteIdent(config.Table), where), args...)
+	if err != nil {
+		writeError(w, codeQuery, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	n, pending := 0, 0
+	lastFlush := time.Now()
+	for rows.Next() {
+		var row streamRow
+		levelValues := make([]string, len(levels))
+		dest := []interface{}{&row.LoggedAt, &row.Tag, &row.Values, &row.Changed}
+		for i := range levelValues {
+			dest = append(dest, &levelValues[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			break
+		}
+		row.LoggedAt = row.LoggedAt.In(displayLocation)
+		row.UNS = make(map[string]string, len(levels))
+		for i, level := range levels {
+			row.UNS[level] = levelValues[i]
+		}
+
+		if err = enc.Encode(row); err != nil {
+			// The client went away
+			logger.DebugContext(r.Context(), "Stream aborted", "rows", n, "error", err)
+			return
+		}
+		n++
+		pending++
+		if flusher != nil && (pending >= streamFlushRows || time.Since(lastFlush) >= streamFlushInterval) {
+			flusher.Flush()
+			pending, lastFlush = 0, time.Now()
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		logger.WarnContext(r.Context(), "Stream failed", "table", config.Table, "rows", n, "error", err)
+		_, body := apiError(codeQuery, http.StatusInternalServerError, err)
+		enc.Encode(body)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Streamed rows", "table", config.Table, "rows", n)
+}
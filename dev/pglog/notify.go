@@ -0,0 +1,96 @@
+package function
+
+import (
+	"encoding/json"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ── Change Notifications ────────────────────────────────────────────
+// With MQTT_URL set, every logged row is also published to the broker
+// so other consumers can react to changes:
+//
+//	MQTT_URL=tcp://fnkit-mqtt:1883
+//	MQTT_CHANGE_TOPIC=v1.0/{enterprise}/{site}/{area}/{line}/_changed
+//
+// The topic template takes UNS levels as {placeholders}. Publishing is
+// best effort: a broker that is down is logged as a warning and never
+// fails the DB write.
+
+const mqttPublishTimeout = 5 * time.Second
+
+var (
+	mqttClient  mqtt.Client
+	changeTopic string
+)
+
+type changeNotification struct {
+	Table    string                 `json:"table"`
+	UNS      map[string]string      `json:"uns"`
+	Tag      string                 `json:"tag"`
+	Changed  []string               `json:"changed"`
+	Values   map[string]interface{} `json:"values"`
+	LoggedAt time.Time              `json:"logged_at"`
+}
+
+func initChangePublisher() {
+	brokerURL := envOrDefault("MQTT_URL", "")
+	if brokerURL == "" {
+		return
+	}
+	changeTopic = envOrDefault("MQTT_CHANGE_TOPIC", "v1.0/{enterprise}/{site}/{area}/{line}/_changed")
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(brokerURL).
+		SetClientID(envOrDefault("FUNCTION_TARGET", "pglog") + "-notify").
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+
+	// With connect retry the client keeps trying in the background, so a
+	// broker that is down at startup isn't fatal
+	mqttClient = mqtt.NewClient(opts)
+	mqttClient.Connect()
+	logger.Info("MQTT change notifications enabled", "broker", brokerURL, "topic", changeTopic)
+}
+
+// publishChange publishes a logged row to the change topic without
+// blocking the request.
+func publishChange(row logRow) {
+	if mqttClient == nil {
+		return
+	}
+
+	payload, err := json.Marshal(newChangeNotification(row))
+	if err != nil {
+		logger.Warn("Failed to encode change notification", "error", err)
+		return
+	}
+
+	topic := expandLevels(changeTopic, row.UNS)
+	token := mqttClient.Publish(topic, 1, false, payload)
+	go func() {
+		if !token.WaitTimeout(mqttPublishTimeout) {
+			logger.Warn("Timed out publishing change notification", "topic", topic)
+		} else if err := token.Error(); err != nil {
+			logger.Warn("Failed to publish change notification", "topic", topic, "error", err)
+		}
+	}()
+}
+
+// newChangeNotification builds the message published for a logged row.
+func newChangeNotification(row logRow) changeNotification {
+	loggedAt := row.LoggedAt
+	if loggedAt.IsZero() {
+		loggedAt = time.Now() // batched rows don't read logged_at back
+	}
+
+	return changeNotification{
+		Table:    row.Config.Table,
+		UNS:      row.UNS.Levels,
+		Tag:      row.Tag,
+		Changed:  row.Changed,
+		Values:   row.Values,
+		LoggedAt: loggedAt.UTC(),
+	}
+}
@@ -0,0 +1,56 @@
+package function
+
+import (
+	"fmt"
+	"time"
+)
+
+// ── Partitioning ────────────────────────────────────────────────────
+// With "partition": "monthly" the log table is created as a parent
+// partitioned by RANGE (logged_at), with one child table per calendar
+// month (UTC) named {table}_pYYYYMM:
+//
+//	uns_log_p202601  FOR VALUES FROM ('2026-01-01') TO ('2026-02-01')
+//
+// ensureTable creates the partitions for the current and the next month
+// on every invocation, so inserts around midnight at month end never miss
+// a partition. Old months can be dropped with DROP TABLE.
+//
+// An existing plain table can't be converted in place: switching an
+// existing table to "monthly" fails when the first partition is created.
+
+const partitionMonthly = "monthly"
+
+// partitionSuffixLen is the length of the "_pYYYYMM" child table suffix.
+const partitionSuffixLen = 8
+
+func validatePartition(partition, table string) error {
+	switch partition {
+	case "":
+		return nil
+	case partitionMonthly:
+		if len(table)+partitionSuffixLen > maxIdentifierLen {
+			return fmt.Errorf("table %q is too long for partition names (max %d bytes)",
+				table, maxIdentifierLen-partitionSuffixLen)
+		}
+		return nil
+	default:
+		return fmt.Errorf("partition must be empty or %q", partitionMonthly)
+	}
+}
+
+// partitionDDL returns the statements creating the monthly partitions
+// covering now and the following month.
+func partitionDDL(table string, now time.Time) string {
+	month := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var ddl string
+	for i := 0; i < 2; i++ {
+		from := month.AddDate(0, i, 0)
+		to := from.AddDate(0, 1, 0)
+		ddl += fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s');\n",
+			quoteIdent(fmt.Sprintf("%s_p%s", table, from.Format("200601"))), quoteIdent(table),
+			from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return ddl
+}
@@ -0,0 +1,116 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ── Normalized Points ───────────────────────────────────────────────
+// Filtering on one tag of the values JSONB ("temperature > 90") needs an
+// expression index per tag. With "normalize_points": true every logged
+// row also writes one point per tag of its values to {table}_points:
+//
+//	SELECT r.* FROM uns_log r JOIN uns_log_points p ON p.row_id = r.id
+//	WHERE p.tag = 'temperature' AND p.num_value > 90
+//
+// Numbers (and the average of aggregate windows) go to num_value,
+// everything else to str_value, objects and arrays as JSON. Empty values
+// get no point. The points are written by the same statement as their
+// row, so they share its transaction and mirrors; row_id has no foreign
+// key, as a partitioned log's key includes logged_at.
+
+const pointsSuffix = "_points"
+
+func pointsTable(table string) string {
+	return table + pointsSuffix
+}
+
+func validatePoints(config *pglogConfig) error {
+	if !config.NormalizePoints {
+		return nil
+	}
+	if err := validateIdentifier(pointsTable(config.Table)); err != nil {
+		return fmt.Errorf("normalize_points: %v", err)
+	}
+	return nil
+}
+
+// ensurePointsTable creates {table}_points.
+func ensurePointsTable(ctx context.Context, config *pglogConfig) error {
+	table := pointsTable(config.Table)
+	pool, err := dbFor(config)
+	if err != nil {
+		return err
+	}
+	_, err = pool.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			row_id      BIGINT            NOT NULL,
+			logged_at   TIMESTAMPTZ       NOT NULL,
+			tag         TEXT              NOT NULL,
+			num_value   DOUBLE PRECISION,
+			str_value   TEXT,
+			PRIMARY KEY (row_id, tag)
+		);
+		CREATE INDEX IF NOT EXISTS %s ON %s (tag, logged_at);
+	`, quoteIdent(table), quoteIdent("idx_"+table+"_tag"), quoteIdent(table)))
+	return err
+}
+
+// rowPoints splits the values of a row into the point columns, ordered
+// by tag.
+func rowPoints(row logRow) (tags []string, nums []*float64, strs []*string) {
+	for tag := range row.Values {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	kept := tags[:0]
+	for _, tag := range tags {
+		var num *float64
+		var str *string
+		switch v := row.Values[tag].(type) {
+		case nil:
+			continue
+		case float64:
+			num = &v
+		case aggregateStats:
+			num = &v.Avg
+		case string:
+			str = &v
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			s := string(b)
+			str = &s
+		}
+		kept = append(kept, tag)
+		nums = append(nums, num)
+		strs = append(strs, str)
+	}
+	return kept, nums, strs
+}
+
+// withPoints wraps a row's INSERT so it also writes the row's points,
+// still returning the row's logged_at.
+func withPoints(row logRow, insert string, args []interface{}) (string, []interface{}) {
+	tags, nums, strs := rowPoints(row)
+	n := len(args)
+	args = append(args, tags, nums, strs)
+
+	query := fmt.Sprintf(`
+		WITH inserted AS (%s
+			RETURNING id, logged_at
+		), points AS (
+			INSERT INTO %s (row_id, logged_at, tag, num_value, str_value)
+			SELECT inserted.id, inserted.logged_at, p.tag, p.num_value, p.str_value
+			FROM inserted, unnest($%d::text[], $%d::float8[], $%d::text[]) AS p(tag, num_value, str_value)
+		)
+		SELECT logged_at FROM inserted
+	`, strings.TrimRight(insert, "\n\t "), quoteIdent(pointsTable(row.Config.Table)), n+1, n+2, n+3)
+	return query, args
+}
@@ -0,0 +1,53 @@
+package function
+
+import (
+	"context"
+	"time"
+)
+
+// ── Pre-warming ─────────────────────────────────────────────────────
+// With PREWARM=true init starts a background warm-up, so the first
+// request doesn't pay for it: the FUNCTION_TARGET config is fetched from
+// S3 (and cached), its wildcard topics are expanded, the persisted last
+// snapshot is loaded and the topics are read from the cache once, which
+// also opens the cache connections. The function registers and serves
+// meanwhile; failures are only logged and the first request retries as
+// usual.
+
+func initPrewarm() {
+	if envOrDefault("PREWARM", "") != "true" {
+		return
+	}
+	go prewarm(ctx)
+}
+
+func prewarm(ctx context.Context) {
+	start := time.Now()
+
+	s3Ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	config, err := loadConfig(s3Ctx, "")
+	cancel()
+	if err != nil {
+		logger.WarnContext(ctx, "Pre-warm failed to load config", "error", err)
+		return
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+	if config, err = expandTopics(cacheCtx, config); err != nil {
+		logger.WarnContext(ctx, "Pre-warm failed to expand topics", "error", err)
+		return
+	}
+
+	lastSnapshotMu.Lock()
+	loadLastSnapshot(cacheCtx, config)
+	lastSnapshotMu.Unlock()
+
+	if _, err := readTopicsFromCache(cacheCtx, config); err != nil {
+		logger.WarnContext(ctx, "Pre-warm failed to read the cache", "error", err)
+		return
+	}
+
+	logger.InfoContext(ctx, "Pre-warmed", "table", config.Table,
+		"topics", len(config.Topics), "duration_ms", time.Since(start).Milliseconds())
+}
@@ -0,0 +1,34 @@
+package function
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPrewarmDoneContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"cancelled", cancelled},
+		{"deadline passed", expired},
+	}
+	for _, tt := range tests {
+		done := make(chan struct{})
+		go func() {
+			prewarm(tt.ctx)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: prewarm didn't return", tt.name)
+		}
+	}
+}
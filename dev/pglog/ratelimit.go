@@ -0,0 +1,47 @@
+package function
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ── Rate Limiting ───────────────────────────────────────────────────
+// RATE_LIMIT_PER_MINUTE caps the invocations per line (the UNS levels of
+// the config's first topic, e.g. acme/factory1/mixing/line1) with a token
+// bucket refilled at that rate and holding up to one minute's budget.
+// Over budget, a request gets 429 with Retry-After before any Postgres
+// work. 0 (the default) disables it; dry runs are never limited.
+//
+// Limiters live in process memory, so with N replicas a line can get up
+// to N times the budget.
+
+var (
+	rateLimitPerMinute = envIntOrDefault("RATE_LIMIT_PER_MINUTE", 0)
+	lineLimitersMu     sync.Mutex
+	lineLimiters       = make(map[string]*rate.Limiter)
+)
+
+// rateLimitDelay takes a token from the line's bucket and returns 0, or,
+// when the bucket is empty, how long until the next token (taking none).
+func rateLimitDelay(line string) time.Duration {
+	if rateLimitPerMinute <= 0 {
+		return 0
+	}
+
+	lineLimitersMu.Lock()
+	limiter, ok := lineLimiters[line]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(rateLimitPerMinute)), rateLimitPerMinute)
+		lineLimiters[line] = limiter
+	}
+	lineLimitersMu.Unlock()
+
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay > 0 {
+		reservation.Cancel()
+	}
+	return delay
+}
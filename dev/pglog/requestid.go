@@ -0,0 +1,82 @@
+package function
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// ── Request IDs ─────────────────────────────────────────────────────
+// Every HTTP request gets an ID, taken from the X-Request-ID header or
+// generated. It is added to every log line written with the request's
+// context (as "request_id"), returned in the X-Request-ID response header
+// and added as "request_id" to JSON object responses. CloudEvents use the
+// event ID.
+
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds caller-supplied IDs, longer ones are replaced.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// withRequestID returns r with its request ID in the context, and the ID.
+func withRequestID(r *http.Request) (*http.Request, string) {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	return r.WithContext(contextWithRequestID(r.Context(), id)), id
+}
+
+func contextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts printable ASCII IDs of up to maxRequestIDLength,
+// so IDs can't inject into headers or log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestIDHandler adds the request ID of the record's context to each log
+// record.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
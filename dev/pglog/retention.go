@@ -0,0 +1,80 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ── Retention ───────────────────────────────────────────────────────
+// With "retention_days": N rows older than N days are deleted from the
+// log table (and {table}_points, see points.go). Pruning piggybacks on normal invocations and runs at most
+// once per pruneInterval per table, so no separate cron job is needed.
+
+const pruneInterval = time.Hour
+
+var (
+	lastPrune   = make(map[string]time.Time) // table → last prune attempt
+	lastPruneMu sync.Mutex
+)
+
+// pruneStatus reports a prune that ran during this invocation.
+type pruneStatus struct {
+	ran     bool
+	deleted int64
+}
+
+// annotate adds the number of deleted rows to the response fields when a
+// prune ran.
+func (p pruneStatus) annotate(resp map[string]interface{}) {
+	if p.ran {
+		resp["pruned"] = p.deleted
+	}
+}
+
+// pruneOldRows deletes expired rows if retention is configured and the
+// table hasn't been pruned within pruneInterval. Failures are logged and
+// retried on the next invocation rather than failing the request.
+func pruneOldRows(ctx context.Context, config *pglogConfig) pruneStatus {
+	if config.RetentionDays <= 0 {
+		return pruneStatus{}
+	}
+
+	lastPruneMu.Lock()
+	if time.Since(lastPrune[config.Table]) < pruneInterval {
+		lastPruneMu.Unlock()
+		return pruneStatus{}
+	}
+	lastPrune[config.Table] = time.Now()
+	lastPruneMu.Unlock()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE logged_at < NOW() - make_interval(days => $1)`,
+		quoteIdent(config.Table))
+
+	pool, err := dbFor(config)
+	var tag pgconn.CommandTag
+	if err == nil {
+		tag, err = pool.Exec(ctx, query, config.RetentionDays)
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to prune old rows", "table", config.Table, "error", err)
+		lastPruneMu.Lock()
+		delete(lastPrune, config.Table)
+		lastPruneMu.Unlock()
+		return pruneStatus{}
+	}
+
+	deleted := tag.RowsAffected()
+	if config.NormalizePoints {
+		query := fmt.Sprintf(`DELETE FROM %s WHERE logged_at < NOW() - make_interval(days => $1)`,
+			quoteIdent(pointsTable(config.Table)))
+		if _, err := pool.Exec(ctx, query, config.RetentionDays); err != nil {
+			logger.WarnContext(ctx, "Failed to prune old points", "table", pointsTable(config.Table), "error", err)
+		}
+	}
+	logger.InfoContext(ctx, "Pruned old rows", "table", config.Table, "retention_days", config.RetentionDays, "deleted", deleted)
+	return pruneStatus{ran: true, deleted: deleted}
+}
@@ -0,0 +1,84 @@
+package function
+
+import (
+	"fmt"
+	"math"
+)
+
+// ── Rounding ────────────────────────────────────────────────────────
+// Sensors often report more precision than they have (72.4999999998),
+// which shows up as noise in change detection and bloats the values
+// JSONB. Numeric readings can be rounded to a number of decimal places
+// per tag, with an optional default:
+//
+//	"round": { "temperature": 1, "speed": 0 },
+//	"default_round": 3
+//
+// Rounding is applied as soon as values are read (after any transform,
+// see transform.go), so the rounded value is what gets compared, stored
+// and kept as the last snapshot. Unlike a deadband, it changes the stored
+// value. Non-numeric values are left alone; vtq payloads have their
+// "value" rounded.
+
+const maxRoundDecimals = 15
+
+func validateRound(config *pglogConfig) error {
+	if d := config.DefaultRound; d != nil && (*d < 0 || *d > maxRoundDecimals) {
+		return fmt.Errorf("default_round must be 0-%d", maxRoundDecimals)
+	}
+	for tag, d := range config.Round {
+		if d < 0 || d > maxRoundDecimals {
+			return fmt.Errorf("round.%s must be 0-%d", tag, maxRoundDecimals)
+		}
+	}
+	return nil
+}
+
+// roundDecimals returns the decimal places for a tag, if it is rounded.
+func (c *pglogConfig) roundDecimals(tag string) (int, bool) {
+	if d, ok := c.Round[tag]; ok {
+		return d, true
+	}
+	if c.DefaultRound != nil {
+		return *c.DefaultRound, true
+	}
+	return 0, false
+}
+
+// roundSnapshot rounds the current and previous reading of every topic.
+func roundSnapshot(config *pglogConfig, snapshot map[string]*topicSnapshot) {
+	if len(config.Round) == 0 && config.DefaultRound == nil {
+		return
+	}
+	for _, topic := range config.Topics {
+		if snap := snapshot[topic]; snap != nil {
+			tag := config.parseTopic(topic).Tag
+			snap.Current = config.roundReading(tag, snap.Current)
+			snap.Previous = config.roundReading(tag, snap.Previous)
+		}
+	}
+}
+
+// roundReading rounds a raw cache value: the value itself, or the "value"
+// field of a vtq payload.
+func (c *pglogConfig) roundReading(tag, raw string) string {
+	if decimals, ok := c.roundDecimals(tag); ok {
+		return c.mapReading(raw, rounder(decimals))
+	}
+	return raw
+}
+
+// roundValue rounds an already extracted (compare) value.
+func (c *pglogConfig) roundValue(tag, value string) string {
+	if decimals, ok := c.roundDecimals(tag); ok {
+		return mapNumber(value, rounder(decimals))
+	}
+	return value
+}
+
+func rounder(decimals int) func(float64) float64 {
+	scale := math.Pow10(decimals)
+	return func(f float64) float64 {
+		return math.Round(f*scale) / scale
+	}
+}
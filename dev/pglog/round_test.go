@@ -0,0 +1,77 @@
+package function
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRoundSnapshot(t *testing.T) {
+	const prefix = "v1.0/acme/factory1/mixing/line1/"
+	tests := []struct {
+		name         string
+		config       string
+		tag          string
+		current      string
+		previous     string
+		wantCurrent  string
+		wantPrevious string
+	}{
+		{"per tag", `"round": {"temperature": 1}`, "temperature", "72.4999999998", "72.46", "72.5", "72.5"},
+		{"zero decimals", `"round": {"speed": 0}`, "speed", "1499.6", "", "1500", ""},
+		{"default", `"round": {"speed": 0}, "default_round": 3`, "pressure", "1.23456", "1.2", "1.235", "1.2"},
+		{"tag overrides default", `"round": {"temperature": 0}, "default_round": 3`, "temperature", "72.46", "", "72", ""},
+		{"not rounded", `"round": {"speed": 0}`, "temperature", "72.46", "", "72.46", ""},
+		{"non-numeric", `"default_round": 1`, "state", "RUNNING", "1.25", "RUNNING", "1.3"},
+		{"no rounding", `"deadband": {}`, "temperature", "72.4999", "", "72.4999", ""},
+		{"vtq value", `"value_schema": "vtq", "default_round": 1`, "temperature",
+			`{"value": 72.46, "quality": "GOOD"}`, "", `{"quality":"GOOD","value":72.5}`, ""},
+		{"vtq unchanged", `"value_schema": "vtq", "default_round": 1`, "temperature",
+			`{"value": 72.5, "quality": "GOOD"}`, "", `{"value": 72.5, "quality": "GOOD"}`, ""},
+	}
+	for _, tt := range tests {
+		topic := prefix + tt.tag
+		config := testConfig(t, `{"topics": ["`+topic+`"], `+tt.config+`}`)
+		snapshot := map[string]*topicSnapshot{topic: {Current: tt.current, Previous: tt.previous}}
+
+		roundSnapshot(config, snapshot)
+		if got := snapshot[topic]; got.Current != tt.wantCurrent || got.Previous != tt.wantPrevious {
+			t.Errorf("%s: rounded to %q/%q, want %q/%q", tt.name, got.Current, got.Previous, tt.wantCurrent, tt.wantPrevious)
+		}
+	}
+}
+
+// roundedChangeTests compare rounded values; the topic's tag is "temp".
+var roundedChangeTests = []struct {
+	name        string
+	config      string
+	current     string
+	previous    string
+	wantChanged []string
+	wantValue   interface{}
+}{
+	{"equal once rounded", `"round": {"temp": 1}`, "21.04", "21.01", nil, 21.0},
+	{"different once rounded", `"round": {"temp": 1}`, "21.06", "21.01", []string{"temp"}, 21.1},
+	{"default", `"default_round": 0`, "1499.6", "1500.2", nil, 1500.0},
+	{"not rounded", `"round": {"speed": 1}`, "21.04", "21.01", []string{"temp"}, 21.04},
+}
+
+func TestRoundedChanges(t *testing.T) {
+	for _, tt := range roundedChangeTests {
+		config := testConfig(t, `{"topics": ["v1.0/acme/factory1/mixing/line1/temp"], "change_source": "prev", `+tt.config+`}`)
+		changed, value := preparedChanges(t, config, tt.current, tt.previous)
+		if !slices.Equal(changed, tt.wantChanged) || value != tt.wantValue {
+			t.Errorf("%s: changed %v, value %v, want %v, %v", tt.name, changed, value, tt.wantChanged, tt.wantValue)
+		}
+	}
+}
+
+func TestRoundedChangesDryRun(t *testing.T) {
+	testCache(t)
+	for _, tt := range roundedChangeTests {
+		config := testConfig(t, `{"topics": ["v1.0/acme/factory1/mixing/line1/temp"], "change_source": "prev", `+tt.config+`}`)
+		changed, value := dryRunChanges(t, config, tt.current, tt.previous)
+		if !slices.Equal(changed, tt.wantChanged) || value != tt.wantValue {
+			t.Errorf("%s: runLog reported changed %v, value %v, want %v, %v", tt.name, changed, value, tt.wantChanged, tt.wantValue)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ── Row Mode ────────────────────────────────────────────────────────
+// "row_mode": "snapshot" (default) logs one row per change carrying the
+// values of every topic. "per_tag" logs one row per changed tag instead,
+// holding only that tag's value (and its prev value, delta and quality),
+// for consumers that want a narrow (tag, value, logged_at) time series:
+//
+//	SELECT logged_at, tag, values->tag AS value FROM uns_log
+//
+// The UNS columns are shared by all rows of an invocation. With vtq
+// payloads each row gets its own tag's source ts.
+//
+// In snapshot mode "max_changed_per_row" bounds the changed array: when
+// more tags changed (e.g. hundreds at a coordinated startup), the change
+// is logged as several rows with the same values, each carrying the next
+// max_changed_per_row changes and their deltas.
+
+const (
+	rowModeSnapshot = "snapshot"
+	rowModePerTag   = "per_tag"
+)
+
+func validateRowMode(config *pglogConfig) error {
+	switch config.RowMode {
+	case rowModeSnapshot, rowModePerTag:
+	default:
+		return fmt.Errorf("row_mode must be %q or %q", rowModeSnapshot, rowModePerTag)
+	}
+	if config.MaxChangedPerRow < 0 {
+		return fmt.Errorf("max_changed_per_row must not be negative")
+	}
+	if config.MaxChangedPerRow > 0 && config.RowMode == rowModePerTag {
+		return fmt.Errorf("max_changed_per_row only applies to row_mode %q", rowModeSnapshot)
+	}
+	return nil
+}
+
+// perTagRows splits a snapshot row into one row per changed tag.
+func perTagRows(row logRow, snapshot map[string]*topicSnapshot) []logRow {
+	rows := make([]logRow, 0, len(row.Changed))
+	for _, entry := range row.Changed {
+		tag := tagOfChange(entry)
+
+		r := row
+		r.Tag = tag
+		r.Changed = []string{entry}
+		r.Values = pickTag(row.Values, tag)
+		if total, ok := row.Values[tag+"_unwrapped"]; ok {
+			r.Values[tag+"_unwrapped"] = total
+		}
+		r.PrevValues = pickTag(row.PrevValues, tag)
+		r.Deltas = pickTag(row.Deltas, tag)
+
+		if row.Config.ValueSchema == valueSchemaVTQ {
+			var quality map[string]string
+			quality, r.SourceTS = vtqMetadata(row.Config, snapshot, tag)
+			r.Quality = pickTag(quality, tag)
+		}
+		if row.Config.hasUnits() {
+			r.Unit = row.Config.tagUnit(snapshot, tag)
+		}
+
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// splitChangedRows splits a snapshot row into rows of at most max changes
+// each; the first changed tag of each is its trigger tag.
+func splitChangedRows(row logRow, max int, snapshot map[string]*topicSnapshot) []logRow {
+	if max <= 0 || len(row.Changed) <= max {
+		return []logRow{row}
+	}
+
+	rows := make([]logRow, 0, (len(row.Changed)+max-1)/max)
+	for start := 0; start < len(row.Changed); start += max {
+		changed := row.Changed[start:min(start+max, len(row.Changed))]
+
+		r := row
+		r.Tag = tagOfChange(changed[0])
+		r.Changed = changed
+		if row.Deltas != nil {
+			r.Deltas = make(map[string]float64)
+			for _, entry := range changed {
+				if delta, ok := row.Deltas[tagOfChange(entry)]; ok {
+					r.Deltas[tagOfChange(entry)] = delta
+				}
+			}
+		}
+		if row.Config.ValueSchema == valueSchemaVTQ {
+			_, r.SourceTS = vtqMetadata(row.Config, snapshot, r.Tag)
+		}
+		if row.Config.hasUnits() {
+			r.Unit = row.Config.tagUnit(snapshot, r.Tag)
+		}
+
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// pickTag returns a map holding only tag (empty when m lacks it, nil when
+// m is nil).
+func pickTag[V any](m map[string]V, tag string) map[string]V {
+	if m == nil {
+		return nil
+	}
+	picked := make(map[string]V, 1)
+	if v, ok := m[tag]; ok {
+		picked[tag] = v
+	}
+	return picked
+}
+
+// insertEach inserts the rows one by one and returns how many made it;
+// on error the rest (from that row on) are not inserted.
+func insertEach(ctx context.Context, rows []logRow) (int, error) {
+	for i := range rows {
+		err := dbWrite(func() error {
+			return insertRow(ctx, &rows[i])
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(rows), nil
+}
+
+// ── Tag Column ──────────────────────────────────────────────────────
+// "trigger_tag_strategy" decides what a snapshot row's tag column holds
+// when several tags changed together:
+//
+//	"first" (default)  the first changed tag, as before
+//	"joined"           every changed tag, comma-joined
+//	"none"             NULL, as the changed array already lists them
+//
+// "none" drops the NOT NULL of the tag column (see migrate.go). per_tag
+// and aggregate rows always carry their tag.
+
+const (
+	tagStrategyFirst  = "first"
+	tagStrategyJoined = "joined"
+	tagStrategyNone   = "none"
+)
+
+func validateTagStrategy(config *pglogConfig) error {
+	switch config.TriggerTagStrategy {
+	case tagStrategyFirst, tagStrategyJoined, tagStrategyNone:
+		return nil
+	default:
+		return fmt.Errorf("trigger_tag_strategy must be %q, %q or %q", tagStrategyFirst, tagStrategyJoined, tagStrategyNone)
+	}
+}
+
+// tagColumn returns the value of the row's tag column (nil = NULL).
+func (row logRow) tagColumn() interface{} {
+	if row.Config.RowMode == rowModePerTag || row.Tag == aggregateTag {
+		return row.Tag
+	}
+	switch row.Config.TriggerTagStrategy {
+	case tagStrategyJoined:
+		var tags []string
+		for _, entry := range row.Changed {
+			if tag := tagOfChange(entry); !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		return strings.Join(tags, ",")
+	case tagStrategyNone:
+		return nil
+	}
+	return row.Tag
+}
+
+// ── Snapshot Scope ──────────────────────────────────────────────────
+// "snapshot_scope": "all" (default) stores the value of every topic in a
+// row's values, "changed" only those of its changed tags (and their
+// counter totals), trading context for storage. prev_values are narrowed
+// the same way; deltas only ever cover changed tags. Typed columns of
+// unchanged tags are then NULL, and maintain_current still merges to the
+// full state.
+
+const (
+	snapshotScopeAll     = "all"
+	snapshotScopeChanged = "changed"
+)
+
+func validateSnapshotScope(config *pglogConfig) error {
+	switch config.SnapshotScope {
+	case snapshotScopeAll, snapshotScopeChanged:
+		return nil
+	default:
+		return fmt.Errorf("snapshot_scope must be %q or %q", snapshotScopeAll, snapshotScopeChanged)
+	}
+}
+
+// scopeValues narrows the values of the rows to their changed tags when
+// snapshot_scope is "changed".
+func scopeValues(rows []logRow) []logRow {
+	for i, row := range rows {
+		if row.Config.SnapshotScope != snapshotScopeChanged {
+			continue
+		}
+		values := make(map[string]interface{}, len(row.Changed))
+		prev := make(map[string]interface{}, len(row.Changed))
+		for _, entry := range row.Changed {
+			tag := tagOfChange(entry)
+			for _, key := range []string{tag, tag + "_unwrapped"} {
+				if v, ok := row.Values[key]; ok {
+					values[key] = v
+				}
+				if v, ok := row.PrevValues[key]; ok {
+					prev[key] = v
+				}
+			}
+		}
+		rows[i].Values = values
+		if row.PrevValues != nil {
+			rows[i].PrevValues = prev
+		}
+	}
+	return rows
+}
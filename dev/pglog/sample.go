@@ -0,0 +1,97 @@
+package function
+
+import (
+	"context"
+	"fmt"
+)
+
+// ── Sampling ────────────────────────────────────────────────────────
+// Very chatty tags can be thinned to a representative trace by logging
+// only every Nth detected change:
+//
+//	"sample_every": 10,
+//	"sample": { "vibration": 100, "alarm": 1 }
+//
+// detectChanges counts the changes of each topic and only lets every Nth
+// through; the value current at that change is the one logged. Skipped
+// changes still advance the topic's last value, so each change is counted
+// once, and the tag's current value still appears in the values of rows
+// triggered by other tags. 0 or 1 logs every change. The sample decisions
+// are reported as "sampled" in the response. Like debounce timestamps the
+// counters live in process memory only, and dry runs don't advance them.
+
+var sampleCounts = make(map[string]int) // stateKey → changes since the last sampled one, guarded by lastSnapshotMu
+
+// sampleDecision is a counted change, reported in the response.
+type sampleDecision struct {
+	Tag    string `json:"tag"`
+	Change int    `json:"change"` // 1..Every
+	Every  int    `json:"every"`
+	Logged bool   `json:"logged"`
+}
+
+func (c *pglogConfig) sampleFor(tag string) int {
+	if n, ok := c.Sample[tag]; ok {
+		return n
+	}
+	return c.SampleEvery
+}
+
+func validateSample(config *pglogConfig) error {
+	if config.SampleEvery < 0 {
+		return fmt.Errorf("sample_every must not be negative")
+	}
+	for tag, n := range config.Sample {
+		if n < 0 {
+			return fmt.Errorf("sample.%s must not be negative", tag)
+		}
+	}
+	return nil
+}
+
+// sampledOut counts a detected change to the topic and reports whether
+// it falls between samples. Callers must hold lastSnapshotMu.
+func sampledOut(config *pglogConfig, topic, tag string, snap *topicSnapshot) bool {
+	every := config.sampleFor(tag)
+	if every <= 1 {
+		return false
+	}
+	n := sampleCounts[config.stateKey(topic)] + 1
+	snap.Sample = &sampleDecision{Tag: tag, Change: n, Every: every, Logged: n >= every}
+	return !snap.Sample.Logged
+}
+
+// recordSamples advances the counters of the sampled topics and the last
+// value of those whose change was skipped.
+func recordSamples(ctx context.Context, config *pglogConfig, snapshot map[string]*topicSnapshot) {
+	var skipped []string
+	lastSnapshotMu.Lock()
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		if snap == nil || snap.Sample == nil {
+			continue
+		}
+		if snap.Sample.Logged {
+			delete(sampleCounts, config.stateKey(topic))
+		} else {
+			sampleCounts[config.stateKey(topic)] = snap.Sample.Change
+			skipped = append(skipped, topic)
+		}
+	}
+	lastSnapshotMu.Unlock()
+
+	if len(skipped) > 0 {
+		updateLastSnapshot(ctx, config, skipped, snapshot)
+	}
+}
+
+// sampleDecisions returns the sample decisions of this run.
+func sampleDecisions(config *pglogConfig, snapshot map[string]*topicSnapshot) []sampleDecision {
+	var decisions []sampleDecision
+	for _, topic := range config.Topics {
+		if snap := snapshot[topic]; snap != nil && snap.Sample != nil {
+			decisions = append(decisions, *snap.Sample)
+		}
+	}
+	return decisions
+}
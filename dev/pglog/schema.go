@@ -0,0 +1,153 @@
+package function
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// ── Value Schemas ───────────────────────────────────────────────────
+// "schemas" holds a JSON Schema per tag that its value must satisfy,
+// inline or as the key of an S3 object in S3_BUCKET:
+//
+//	"schemas": {
+//	  "temperature": { "type": "number", "minimum": -40 },
+//	  "recipe": "schemas/recipe.json"
+//	}
+//
+// A value that doesn't validate is excluded before change detection (as
+// if it were missing), listed in the response's "schema_errors" and, with
+// capture_errors, recorded in {table}_errors. The value is validated as
+// it would be stored, so "72.5" is the number 72.5.
+//
+// Schemas are compiled with santhosh-tekuri/jsonschema: draft 2020-12
+// unless "$schema" says otherwise, with "format" asserted. "$ref" may
+// point within the schema (#/$defs/...) but not to other documents.
+// Schema objects are loaded with the config.
+
+const reasonSchema = "schema"
+
+// schemaError is a value excluded by its schema.
+type schemaError struct {
+	Topic string `json:"topic"`
+	Tag   string `json:"tag"`
+	Error string `json:"error"`
+}
+
+// validateSchemas compiles the inline schemas; S3 keys are compiled by
+// loadSchemas.
+func validateSchemas(config *pglogConfig) error {
+	config.compiledSchemas = make(map[string]*jsonschema.Schema, len(config.Schemas))
+	for tag, raw := range config.Schemas {
+		var key string
+		if err := json.Unmarshal(raw, &key); err == nil {
+			if strings.TrimSpace(key) == "" {
+				return fmt.Errorf("schemas.%s: S3 key is empty", tag)
+			}
+			continue
+		}
+		schema, err := compileSchema(tag, raw)
+		if err != nil {
+			return fmt.Errorf("schemas.%s: %v", tag, err)
+		}
+		config.compiledSchemas[tag] = schema
+	}
+	return nil
+}
+
+// loadSchemas reads and compiles the schemas given as S3 keys.
+func loadSchemas(ctx context.Context, config *pglogConfig) error {
+	bucket := envOrDefault("S3_BUCKET", "")
+	for tag, raw := range config.Schemas {
+		var key string
+		if err := json.Unmarshal(raw, &key); err != nil {
+			continue
+		}
+		result, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return fmt.Errorf("schemas.%s: failed to read s3://%s/%s: %w", tag, bucket, key, err)
+		}
+		body, err := io.ReadAll(result.Body)
+		result.Body.Close()
+		if err != nil {
+			return fmt.Errorf("schemas.%s: failed to read s3://%s/%s: %w", tag, bucket, key, err)
+		}
+		schema, err := compileSchema(tag, body)
+		if err != nil {
+			return fmt.Errorf("%w: schemas.%s (%s): %v", errInvalidConfig, tag, key, err)
+		}
+		config.compiledSchemas[tag] = schema
+	}
+	return nil
+}
+
+// compileSchema compiles a tag's schema document. References to other
+// documents are refused rather than fetched.
+func compileSchema(tag string, raw []byte) (*jsonschema.Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.AssertFormat = true
+	c.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("$ref to %s: only references within the schema are supported", url)
+	}
+
+	loc := "pglog:///schemas/" + url.PathEscape(tag) + ".json"
+	if err := c.AddResource(loc, bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return c.Compile(loc)
+}
+
+// schemaMessage describes why a value failed validation, "" for nil.
+func schemaMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+	for len(ve.Causes) > 0 {
+		ve = ve.Causes[0]
+	}
+	return "value" + ve.InstanceLocation + ": " + ve.Message
+}
+
+// applySchemas excludes the current values that don't satisfy their
+// tag's schema before change detection, and returns them.
+func applySchemas(ctx context.Context, config *pglogConfig, snapshot map[string]*topicSnapshot) []schemaError {
+	if len(config.compiledSchemas) == 0 {
+		return nil
+	}
+
+	var errs []schemaError
+	for _, topic := range config.Topics {
+		tag := config.parseTopic(topic).Tag
+		schema, ok := config.compiledSchemas[tag]
+		snap := snapshot[topic]
+		if !ok || snap == nil || snap.Current == "" {
+			continue
+		}
+
+		msg := schemaMessage(schema.Validate(parseValue(config.compareValue(snap.Current))))
+		if msg == "" {
+			continue
+		}
+		logger.WarnContext(ctx, "Value fails its schema", "topic", topic, "error", msg)
+		errs = append(errs, schemaError{Topic: topic, Tag: tag, Error: msg})
+		snap.Rejected, snap.Reason = snap.Current, reasonSchema
+		snap.Current = ""
+	}
+	return errs
+}
@@ -0,0 +1,69 @@
+package function
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApplySchemas(t *testing.T) {
+	const topic = "v1.0/acme/factory1/mixing/line1/recipe"
+	const schemas = `"schemas": {"recipe": {
+		"type": "object",
+		"required": ["batch"],
+		"properties": {
+			"batch": {"$ref": "#/$defs/id"},
+			"started": {"type": "string", "format": "date-time"}
+		},
+		"$defs": {"id": {"type": "string", "pattern": "^B[0-9]+$"}}
+	}}`
+
+	tests := []struct {
+		name    string
+		current string
+		wantErr string
+	}{
+		{"valid", `{"batch": "B42"}`, ""},
+		{"valid format", `{"batch": "B42", "started": "2024-03-01T12:00:00Z"}`, ""},
+		{"missing property", `{"temp": 1}`, "value: missing properties: 'batch'"},
+		{"pattern via ref", `{"batch": "X1"}`, "value/batch: does not match pattern '^B[0-9]+$'"},
+		{"format asserted", `{"batch": "B1", "started": "yesterday"}`, "value/started: 'yesterday' is not valid 'date-time'"},
+		{"wrong type", `72.5`, "value: expected object, but got number"},
+		{"no value", ``, ""},
+	}
+	for _, tt := range tests {
+		config := testConfig(t, `{"topics": ["`+topic+`"], `+schemas+`}`)
+		snapshot := map[string]*topicSnapshot{topic: {Current: tt.current}}
+
+		errs := applySchemas(context.Background(), config, snapshot)
+		got := ""
+		if len(errs) > 0 {
+			got = errs[0].Error
+		}
+		if got != tt.wantErr {
+			t.Errorf("%s: schema error %q, want %q", tt.name, got, tt.wantErr)
+		}
+		if rejected := snapshot[topic].Current == "" && tt.current != ""; rejected != (tt.wantErr != "") {
+			t.Errorf("%s: value rejected = %v", tt.name, rejected)
+		}
+	}
+}
+
+func TestCompileSchemaRefusesExternalRefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{"local ref", `{"$defs": {"n": {"type": "number"}}, "$ref": "#/$defs/n"}`, false},
+		{"remote ref", `{"$ref": "https://example.com/schema.json"}`, true},
+		{"file ref", `{"$ref": "file:///etc/passwd"}`, true},
+		{"invalid schema", `{"type": 5}`, true},
+		{"not JSON", `{"type": `, true},
+	}
+	for _, tt := range tests {
+		_, err := compileSchema("temp", []byte(tt.raw))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: compileSchema = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
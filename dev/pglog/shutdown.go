@@ -0,0 +1,135 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ── Shutdown ────────────────────────────────────────────────────────
+// On SIGTERM (sent by the runtime on rolling deploys) or SIGINT the
+// pending batch is flushed, webhook deliveries finish, buffered lake
+// rows are written and the Postgres pool, cache client, MQTT connection
+// and Kafka writer are closed before the process exits.
+// SHUTDOWN_TIMEOUT_MS bounds how long that may take.
+
+var (
+	shutdownTimeout = time.Duration(envIntOrDefault("SHUTDOWN_TIMEOUT_MS", 10000)) * time.Millisecond
+	shutdownOnce    sync.Once
+	shutdownErr     error
+)
+
+func handleShutdownSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		sig := <-signals
+		logger.Info("Shutting down", "signal", sig.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		err := Shutdown(ctx)
+		cancel()
+		if err != nil {
+			logger.Error("Shutdown failed", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+}
+
+// Shutdown flushes pending batched inserts and closes all connections.
+// Only the first call does any work; later calls return its result.
+func Shutdown(ctx context.Context) error {
+	shutdownOnce.Do(func() {
+		shutdownErr = shutdown(ctx, liveResources())
+	})
+	return shutdownErr
+}
+
+// shutdownResources is what shutdown flushes and closes, in order. Nil
+// fields are skipped.
+type shutdownResources struct {
+	flushBatch     func(context.Context) []rowResult
+	mqtt           interface{ Disconnect(quiesce uint) }
+	kafka          io.Closer
+	waitWebhooks   func(context.Context) error
+	flushLake      func(context.Context) error
+	closeDatabases func()
+	db             interface{ Close() }
+	cache          io.Closer
+}
+
+// liveResources returns the package's connections and buffers.
+func liveResources() shutdownResources {
+	res := shutdownResources{
+		flushBatch:     flushBatch,
+		waitWebhooks:   waitWebhooks,
+		flushLake:      flushAllLake,
+		closeDatabases: closeDatabases,
+	}
+	if mqttClient != nil {
+		res.mqtt = mqttClient
+	}
+	if kafkaWriter != nil {
+		res.kafka = kafkaWriter
+	}
+	if db != nil {
+		res.db = db
+	}
+	if cache != nil {
+		res.cache = cache
+	}
+	return res
+}
+
+func shutdown(ctx context.Context, res shutdownResources) error {
+	var errs []error
+
+	if res.flushBatch != nil {
+		if results := res.flushBatch(ctx); len(results) > 0 {
+			logger.InfoContext(ctx, "Flushed pending batch", "rows", len(results))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if res.mqtt != nil {
+		res.mqtt.Disconnect(250)
+	}
+	if res.kafka != nil {
+		// Close flushes the messages still queued
+		if err := res.kafka.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if res.waitWebhooks != nil {
+		if err := res.waitWebhooks(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if res.flushLake != nil {
+		if err := res.flushLake(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if res.closeDatabases != nil {
+		res.closeDatabases()
+	}
+	if res.db != nil {
+		res.db.Close()
+	}
+	if res.cache != nil {
+		if err := res.cache.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// fakeResources records the order shutdown flushes and closes things in,
+// failing the steps named in fail.
+type fakeResources struct {
+	calls []string
+	fail  map[string]error
+}
+
+func (f *fakeResources) step(name string) error {
+	f.calls = append(f.calls, name)
+	return f.fail[name]
+}
+
+// fakeConn stands in for the MQTT client, Kafka writer, pool and cache.
+type fakeConn struct {
+	f    *fakeResources
+	name string
+}
+
+func (c fakeConn) Close() error    { return c.f.step(c.name) }
+func (c fakeConn) Disconnect(uint) { c.f.step(c.name) }
+
+// fakePool has the pool's Close, which returns nothing.
+type fakePool struct{ fakeConn }
+
+func (p fakePool) Close() { p.f.step(p.name) }
+
+func (f *fakeResources) resources() shutdownResources {
+	return shutdownResources{
+		flushBatch: func(context.Context) []rowResult {
+			f.step("batch")
+			return nil
+		},
+		mqtt:           fakeConn{f, "mqtt"},
+		kafka:          fakeConn{f, "kafka"},
+		waitWebhooks:   func(context.Context) error { return f.step("webhooks") },
+		flushLake:      func(context.Context) error { return f.step("lake") },
+		closeDatabases: func() { f.step("databases") },
+		db:             fakePool{fakeConn{f, "db"}},
+		cache:          fakeConn{f, "cache"},
+	}
+}
+
+func TestShutdownClosesResources(t *testing.T) {
+	everything := []string{"batch", "mqtt", "kafka", "webhooks", "lake", "databases", "db", "cache"}
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	errKafka, errLake := errors.New("kafka: broken pipe"), errors.New("lake: upload failed")
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		fail    map[string]error
+		wantErr []error
+	}{
+		{"clean", context.Background(), nil, nil},
+		{"expired context", expired, nil, []error{context.Canceled}},
+		{"failures don't stop the rest", context.Background(), map[string]error{"kafka": errKafka, "lake": errLake}, []error{errKafka, errLake}},
+	}
+	for _, tt := range tests {
+		f := &fakeResources{fail: tt.fail}
+		err := shutdown(tt.ctx, f.resources())
+		if !slices.Equal(f.calls, everything) {
+			t.Errorf("%s: calls = %v, want %v", tt.name, f.calls, everything)
+		}
+		if (err == nil) != (len(tt.wantErr) == 0) {
+			t.Errorf("%s: shutdown = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		for _, want := range tt.wantErr {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: shutdown = %v, want it to include %v", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestShutdownSkipsMissingResources(t *testing.T) {
+	if err := shutdown(context.Background(), shutdownResources{}); err != nil {
+		t.Errorf("shutdown with nothing to close = %v", err)
+	}
+}
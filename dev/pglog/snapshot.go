@@ -0,0 +1,154 @@
+package function
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ── S3 Snapshot Export ──────────────────────────────────────────────
+// Periodic full dumps of the cached state of every configured topic,
+// independent of change logging and Postgres. Useful as keyframes for
+// recovery and for bootstrapping new consumers.
+//
+// Opt-in via SNAPSHOT_PREFIX. Triggered by POST /snapshot, and on a
+// timer when SNAPSHOT_INTERVAL (Go duration, e.g. "15m") is set.
+//
+// Object key:
+//   s3://{SNAPSHOT_BUCKET}/{SNAPSHOT_PREFIX}/{FUNCTION_TARGET}/{YYYY}/{MM}/{DD}/{YYYYMMDDTHHMMSSZ}.json
+
+var (
+	snapshotBucket   string
+	snapshotPrefix   string
+	snapshotInterval time.Duration
+)
+
+type snapshotDocument struct {
+	Function string                 `json:"function"`
+	TakenAt  time.Time              `json:"taken_at"`
+	Table    string                 `json:"table"`
+	Values   map[string]interface{} `json:"values"`
+}
+
+func initSnapshotExport() {
+	snapshotPrefix = envOrDefault("SNAPSHOT_PREFIX", "")
+	if snapshotPrefix == "" {
+		return
+	}
+	snapshotBucket = envOrDefault("SNAPSHOT_BUCKET", envOrDefault("S3_BUCKET", ""))
+
+	if raw := envOrDefault("SNAPSHOT_INTERVAL", ""); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil {
+			fatal("Invalid SNAPSHOT_INTERVAL", "value", raw, "error", err)
+		}
+		snapshotInterval = interval
+	}
+
+	if snapshotInterval > 0 {
+		go runSnapshotSchedule(snapshotInterval)
+		logger.Info("S3 snapshots enabled", "bucket", snapshotBucket, "prefix", snapshotPrefix, "interval", snapshotInterval.String())
+	} else {
+		logger.Info("S3 snapshots enabled", "bucket", snapshotBucket, "prefix", snapshotPrefix, "interval", "on demand")
+	}
+}
+
+func runSnapshotSchedule(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		exportCtx, cancel := context.WithTimeout(context.Background(), s3Timeout+cacheTimeout)
+		key, err := exportSnapshot(exportCtx)
+		cancel()
+		if err != nil {
+			logger.Warn("Scheduled snapshot failed", "error", err)
+		} else {
+			logger.Info("Wrote snapshot", "bucket", snapshotBucket, "key", key)
+		}
+	}
+}
+
+func snapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if snapshotPrefix == "" {
+		writeError(w, codeNotEnabled, http.StatusNotFound, errors.New("snapshot export needs SNAPSHOT_PREFIX"))
+		return
+	}
+
+	exportCtx, cancel := context.WithTimeout(r.Context(), s3Timeout+cacheTimeout)
+	defer cancel()
+
+	key, err := exportSnapshot(exportCtx)
+	if err != nil {
+		writeError(w, codeSnapshotExport, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"exported": true,
+		"bucket":   snapshotBucket,
+		"key":      key,
+	})
+}
+
+// exportSnapshot reads every configured topic and writes the full state
+// to a timestamped S3 object, returning its key.
+func exportSnapshot(ctx context.Context) (string, error) {
+	if snapshotBucket == "" {
+		return "", fmt.Errorf("SNAPSHOT_BUCKET not configured")
+	}
+
+	config, err := loadConfig(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	if config, err = expandTopics(ctx, config); err != nil {
+		return "", fmt.Errorf("failed to expand topics: %w", err)
+	}
+
+	snapshot, err := readTopicsFromCache(ctx, config)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	doc := snapshotDocument{
+		Function: envOrDefault("FUNCTION_TARGET", "pglog"),
+		TakenAt:  time.Now().UTC(),
+		Table:    config.Table,
+		Values:   make(map[string]interface{}, len(config.Topics)),
+	}
+	for _, topic := range config.Topics {
+		if snap := snapshot[topic]; snap != nil && snap.Current != "" {
+			doc.Values[topic] = parseValue(snap.Current)
+		} else {
+			doc.Values[topic] = nil
+		}
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	key := fmt.Sprintf("%s/%s/%s/%s.json",
+		snapshotPrefix, doc.Function, doc.TakenAt.Format("2006/01/02"), doc.TakenAt.Format("20060102T150405Z"))
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(snapshotBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to write s3://%s/%s: %w", snapshotBucket, key, err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,42 @@
+package function
+
+// ── Cache Stats ─────────────────────────────────────────────────────
+// With ?stats=true the response carries a "stats" object describing what
+// was read from the cache, to spot upstream publishing problems early
+// (alert on a falling found/topics ratio):
+//
+//	"stats": {"topics": 12, "found": 11, "missing": 1, "numeric": 9, "string": 1, "other": 1}
+//
+// "other" counts booleans, objects and arrays. The counts are taken
+// before hold_last_value fills in empty topics.
+
+type cacheStats struct {
+	Topics  int `json:"topics"`
+	Found   int `json:"found"`
+	Missing int `json:"missing"`
+	Numeric int `json:"numeric"`
+	String  int `json:"string"`
+	Other   int `json:"other"`
+}
+
+func snapshotStats(config *pglogConfig, snapshot map[string]*topicSnapshot) cacheStats {
+	stats := cacheStats{Topics: len(config.Topics)}
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		if snap == nil || snap.Current == "" {
+			stats.Missing++
+			continue
+		}
+		stats.Found++
+
+		switch parseValue(config.compareValue(snap.Current)).(type) {
+		case float64:
+			stats.Numeric++
+		case string:
+			stats.String++
+		default:
+			stats.Other++
+		}
+	}
+	return stats
+}
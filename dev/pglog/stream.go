@@ -0,0 +1,201 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ── Stream Cache Layout ─────────────────────────────────────────────
+// Writers that append every update to a Redis Stream instead of
+// overwriting a key select:
+//
+//	"cache_layout": "stream",
+//	"stream_key": "stream:{line}"
+//
+// stream_key is relative to the key prefix (so "uns:stream:line1") and may
+// use any UNS level as a {placeholder}. Entries carry "topic" and "value"
+// fields; entries for topics outside the config are skipped.
+//
+// Each invocation reads up to streamReadCount entries after the last
+// consumed ID of every stream, replays them in ID order through change
+// detection like a backfill (see backfill.go), inserts the rows in one
+// transaction with logged_at set to the entry time, and only then
+// advances the stored ID:
+//
+//	{prefix}:pglog:lastid:{config}:{stream_key}  →  1739197800123-0
+//
+// {config} is the config's stateID, so configs logging the same stream
+// to one table each consume every entry.
+// so every update is seen once, in order, even between invocations. A
+// failed insert leaves the ID in place and the entries are read again.
+// Dry runs don't advance it.
+
+const (
+	cacheLayoutStream = "stream"
+	streamReadCount   = 1000
+)
+
+// streamKey returns the stream holding a topic's updates.
+func (c *pglogConfig) streamKey(uns unsFields) string {
+	return c.cachePrefix() + ":" + expandLevels(c.StreamKey, uns)
+}
+
+// lastIDKey returns the key storing the last consumed ID of a stream.
+func (c *pglogConfig) lastIDKey(stream string) string {
+	return c.cachePrefix() + ":pglog:lastid:" + c.stateID() + ":" + strings.TrimPrefix(stream, c.cachePrefix()+":")
+}
+
+// runStream consumes the new entries of the config's streams.
+func runStream(ctx context.Context, config *pglogConfig, dryRun bool, extra map[string]interface{}) (int, interface{}) {
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	entries, lastIDs, err := readStreams(cacheCtx, config)
+	if err != nil {
+		return apiError(codeCacheRead, http.StatusInternalServerError, err)
+	}
+
+	rows, state := backfillRows(ctx, config, entries)
+	changed := []string{}
+	for _, row := range rows {
+		changed = append(changed, row.Changed...)
+	}
+
+	resp := map[string]interface{}{
+		"logged":  len(rows) > 0 && !dryRun,
+		"table":   config.Table,
+		"entries": len(entries),
+		"rows":    len(rows),
+		"changed": changed,
+	}
+	if dryRun {
+		resp["dry_run"] = true
+		return http.StatusOK, withExtra(resp, extra)
+	}
+
+	if len(rows) > 0 {
+		metricChangesDetected.WithLabelValues(config.parseTopic(config.Topics[0]).Line).Add(float64(len(changed)))
+
+		dbCtx, cancel := context.WithTimeout(ctx, dbTimeout)
+		err = dbWrite(func() error { return insertBackfill(dbCtx, config, rows) })
+		cancel()
+		if err != nil {
+			metricInsertErrors.Inc()
+			return apiError(codeInsert, http.StatusInternalServerError, err)
+		}
+		countInserted(ctx, len(rows))
+		for _, row := range rows {
+			logInserted(ctx, row)
+			publishChange(row)
+			publishKafka(row)
+			publishWebhook(row)
+			bufferLake(row)
+		}
+	}
+
+	updateLastSnapshot(cacheCtx, config, config.Topics, state)
+
+	// The rows are committed; a failed update only means the entries are
+	// read (and logged) again next time
+	for stream, id := range lastIDs {
+		if err := cache.Set(cacheCtx, config.lastIDKey(stream), id, 0).Err(); err != nil {
+			logger.WarnContext(ctx, "Failed to store stream position", "stream", stream, "error", err)
+		}
+	}
+	resp["last_ids"] = lastIDs
+
+	return http.StatusOK, withExtra(resp, extra)
+}
+
+// readStreams returns the entries after the stored position of every
+// stream, ordered by ID, and the last ID read per stream.
+func readStreams(ctx context.Context, config *pglogConfig) ([]bufferedValue, map[string]string, error) {
+	configured := make(map[string]bool, len(config.Topics))
+	var streams []string
+	seen := make(map[string]bool)
+	for _, topic := range config.Topics {
+		configured[topic] = true
+		if stream := config.streamKey(config.parseTopic(topic)); !seen[stream] {
+			seen[stream] = true
+			streams = append(streams, stream)
+		}
+	}
+
+	var entries []bufferedValue
+	lastIDs := make(map[string]string)
+
+	for _, stream := range streams {
+		start, err := cache.Get(ctx, config.lastIDKey(stream)).Result()
+		if errors.Is(err, redis.Nil) {
+			start = "0"
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		messages, err := cache.XRangeN(ctx, stream, "("+start, "+", streamReadCount).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		lastIDs[stream] = messages[len(messages)-1].ID
+
+		for _, msg := range messages {
+			topic, _ := msg.Values["topic"].(string)
+			value, ok := msg.Values["value"].(string)
+			if !ok || !configured[topic] {
+				continue
+			}
+			ts, err := streamIDTime(msg.ID)
+			if err != nil {
+				logger.WarnContext(ctx, "Skipping stream entry", "stream", stream, "id", msg.ID, "error", err)
+				continue
+			}
+			tag := config.parseTopic(topic).Tag
+			value = config.decodeValue(topic, value)
+			if value == "" {
+				continue
+			}
+			value = config.roundValue(tag, config.transformValue(tag, config.compareValue(value)))
+			entries = append(entries, bufferedValue{Topic: topic, Value: value, TS: ts})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].TS.Before(entries[j].TS)
+	})
+	return entries, lastIDs, nil
+}
+
+// readStreamState returns the last consumed value of every topic, for
+// readers that need a snapshot (e.g. S3 snapshot exports).
+func readStreamState(ctx context.Context, config *pglogConfig) map[string]*topicSnapshot {
+	lastSnapshotMu.Lock()
+	defer lastSnapshotMu.Unlock()
+	loadLastSnapshot(ctx, config)
+
+	snapshot := make(map[string]*topicSnapshot, len(config.Topics))
+	for _, topic := range config.Topics {
+		snapshot[topic] = &topicSnapshot{Current: lastSnapshot[config.stateKey(topic)]}
+	}
+	return snapshot
+}
+
+// streamIDTime returns the time part of a stream entry ID ("<ms>-<seq>").
+func streamIDTime(id string) (time.Time, error) {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed stream ID %q", id)
+	}
+	return time.UnixMilli(n), nil
+}
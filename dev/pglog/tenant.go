@@ -0,0 +1,53 @@
+package function
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ── Tenant Isolation ────────────────────────────────────────────────
+// Several customers can share one Valkey, separated by a tenant segment
+// in the key prefix. With CACHE_TENANT=acme every key this function reads
+// or writes lives under:
+//   {CACHE_KEY_PREFIX}:acme:...
+//
+// Guarantees:
+//   - the tenant ID is validated at startup and cannot contain ':'
+//   - every configured topic must resolve to a key under the tenant
+//     prefix; topics containing ':' or glob characters are rejected
+//   - logged rows are stamped with the tenant in the "tenant" column
+
+var (
+	tenantID      string
+	tenantPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+)
+
+func initTenant() {
+	tenantID = envOrDefault("CACHE_TENANT", "")
+	if tenantID == "" {
+		return
+	}
+	if !tenantPattern.MatchString(tenantID) {
+		fatal("Invalid CACHE_TENANT", "value", tenantID, "pattern", tenantPattern.String())
+	}
+	keyPrefix = keyPrefix + ":" + tenantID
+	logger.Info("Tenant isolation enabled", "tenant", tenantID, "key_prefix", keyPrefix)
+}
+
+// validateTopicKey rejects topics whose resolved keys could escape the
+// configured (tenant) prefix or match other keys when scanned.
+func validateTopicKey(topic string) error {
+	if strings.ContainsAny(topic, ":*?[]\\") {
+		return fmt.Errorf("topic %q contains a reserved character (: * ? [ ] \\)", topic)
+	}
+	if tenantID == "" {
+		return nil // key templates may drop the prefix, see keys.go
+	}
+	for _, kind := range keyKinds {
+		if !strings.HasPrefix(cacheKey(kind, topic), keyPrefix) {
+			return fmt.Errorf("topic %q resolves outside key prefix %q", topic, keyPrefix)
+		}
+	}
+	return nil
+}
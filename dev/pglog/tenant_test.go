@@ -0,0 +1,59 @@
+package function
+
+import "testing"
+
+// withTenant runs the test under CACHE_TENANT=acme.
+func withTenant(t *testing.T) {
+	t.Helper()
+	oldTenant, oldPrefix := tenantID, keyPrefix
+	tenantID, keyPrefix = "acme", "uns:acme"
+	t.Cleanup(func() { tenantID, keyPrefix = oldTenant, oldPrefix })
+}
+
+func TestValidateTopicKeyTenant(t *testing.T) {
+	withTenant(t)
+
+	tests := []struct {
+		topic   string
+		wantErr bool
+	}{
+		{"v1.0/acme/line1/temp", false},
+		{"v1.0/acme/line1/*", true},
+		{"v1.0/acme/line?/temp", true},
+		{"v1.0/acme/line[12]/temp", true},
+		{`v1.0/acme/line1\/temp`, true},
+		{"other:data:v1.0/x", true},
+		{"../other:v1.0/x", true},
+		{":other", true},
+	}
+	for _, tt := range tests {
+		err := validateTopicKey(tt.topic)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateTopicKey(%q) = %v, want error %v", tt.topic, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseKeyTemplateTenant(t *testing.T) {
+	withTenant(t)
+
+	tests := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"{{.Prefix}}:data:{{.Topic}}", false},
+		{"{{.Prefix}}/data/{{.Topic}}", false},
+		{"{{.Prefix}}x:data:{{.Topic}}", true},
+		{"{{.Prefix}}-other:{{.Topic}}", true},
+		{"uns:other:{{.Topic}}", true},
+		{"data:{{.Topic}}", true},
+		{"{{.Prefix}}:data", true},
+		{"{{.Prefix}}:{{.Topic}}:{{.Topic}}", true},
+	}
+	for _, tt := range tests {
+		_, err := parseKeyTemplate("data", tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseKeyTemplate(%q) = %v, want error %v", tt.raw, err, tt.wantErr)
+		}
+	}
+}
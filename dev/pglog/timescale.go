@@ -0,0 +1,126 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ── TimescaleDB ─────────────────────────────────────────────────────
+// With "timescale": true the log table is made a hypertable on
+// logged_at, so TimescaleDB chunks it by time:
+//
+//	"timescale": true,
+//	"timescale_chunk_interval": "7 days",
+//	"timescale_compress_after": "30 days"
+//
+// The chunk interval defaults to TimescaleDB's (7 days). With
+// compress_after, chunks older than that are compressed, segmented by
+// the UNS level columns. Without the timescaledb extension a warning is
+// logged and the table stays a plain one.
+//
+// As with "partition", an existing plain table isn't converted: its
+// primary key lacks logged_at, which hypertables require.
+
+var (
+	timescaleMu        sync.Mutex
+	timescaleAvailable = map[string]bool{} // database ("" = DATABASE_URL) → extension installed
+	hypertables        = map[string]bool{} // database/table set up by this process
+)
+
+var intervalPattern = regexp.MustCompile(`^[0-9]+ ?(seconds?|minutes?|hours?|days?|weeks?|months?|years?)$`)
+
+func validateTimescale(config *pglogConfig) error {
+	if !config.Timescale {
+		if config.TimescaleChunkInterval != "" || config.TimescaleCompressAfter != "" {
+			return fmt.Errorf("timescale_chunk_interval and timescale_compress_after need \"timescale\": true")
+		}
+		return nil
+	}
+	if config.Partition != "" {
+		return fmt.Errorf("timescale and partition can't be combined")
+	}
+	for name, interval := range map[string]string{
+		"timescale_chunk_interval": config.TimescaleChunkInterval,
+		"timescale_compress_after": config.TimescaleCompressAfter,
+	} {
+		if interval != "" && !intervalPattern.MatchString(interval) {
+			return fmt.Errorf("%s must be an interval like \"7 days\"", name)
+		}
+	}
+	return nil
+}
+
+// useHypertable reports whether the config's table is (to be) a
+// hypertable, checking once per database whether the extension is
+// installed.
+func useHypertable(ctx context.Context, config *pglogConfig) (bool, error) {
+	if !config.Timescale {
+		return false, nil
+	}
+
+	timescaleMu.Lock()
+	defer timescaleMu.Unlock()
+	available, checked := timescaleAvailable[config.Database]
+	if !checked {
+		pool, err := dbFor(config)
+		if err != nil {
+			return false, err
+		}
+		err = pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')").Scan(&available)
+		if err != nil {
+			return false, fmt.Errorf("failed to check for timescaledb: %w", err)
+		}
+		if !available {
+			logger.WarnContext(ctx, "timescaledb extension not installed, using a plain table", "table", config.Table)
+		}
+		timescaleAvailable[config.Database] = available
+	}
+	return available, nil
+}
+
+// ensureHypertable converts the freshly created table into a hypertable
+// and sets up compression, once per table and process.
+func ensureHypertable(ctx context.Context, config *pglogConfig) error {
+	timescaleMu.Lock()
+	defer timescaleMu.Unlock()
+	key := config.Database + "/" + config.Table
+	if hypertables[key] {
+		return nil
+	}
+
+	query := "SELECT create_hypertable($1::regclass, 'logged_at', if_not_exists => TRUE)"
+	args := []interface{}{quoteIdent(config.Table)}
+	if config.TimescaleChunkInterval != "" {
+		query = "SELECT create_hypertable($1::regclass, 'logged_at', chunk_time_interval => $2::interval, if_not_exists => TRUE)"
+		args = append(args, config.TimescaleChunkInterval)
+	}
+	pool, err := dbFor(config)
+	if err != nil {
+		return err
+	}
+	if _, err := pool.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to create hypertable: %w", err)
+	}
+
+	if config.TimescaleCompressAfter != "" {
+		segmentBy := strings.Join(config.levelColumns(), ",")
+		_, err := pool.Exec(ctx, fmt.Sprintf("ALTER TABLE %s SET (timescaledb.compress, timescaledb.compress_segmentby = '%s')",
+			quoteIdent(config.Table), segmentBy))
+		if err != nil {
+			return fmt.Errorf("failed to enable compression: %w", err)
+		}
+		_, err = pool.Exec(ctx, "SELECT add_compression_policy($1::regclass, $2::interval, if_not_exists => TRUE)",
+			quoteIdent(config.Table), config.TimescaleCompressAfter)
+		if err != nil {
+			return fmt.Errorf("failed to add compression policy: %w", err)
+		}
+	}
+
+	hypertables[key] = true
+	logger.InfoContext(ctx, "Hypertable ready", "table", config.Table,
+		"chunk_interval", config.TimescaleChunkInterval, "compress_after", config.TimescaleCompressAfter)
+	return nil
+}
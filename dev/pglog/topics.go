@@ -0,0 +1,108 @@
+package function
+
+import (
+	"context"
+	"net/http"
+)
+
+// ── Topic Inspection ────────────────────────────────────────────────
+// GET /pglog/topics?line=line1
+//
+// Lists every configured topic (wildcards expanded) with its UNS fields,
+// the current and previous cache value, the last logged value and whether
+// the current value differs from it — the function's working state,
+// without logging anything. Values are read as for logging (flatten,
+// transform, round), and "changed" compares them like detectChanges does
+// (deadband, counter width) without edge, min_interval or trigger mode.
+// ?line= keeps only the topics of one line.
+
+type topicState struct {
+	Topic      string            `json:"topic"`
+	UNS        map[string]string `json:"uns"`
+	Tag        string            `json:"tag"`
+	Current    interface{}       `json:"current"`
+	Previous   interface{}       `json:"previous"`
+	LastLogged interface{}       `json:"last_logged"`
+	Changed    bool              `json:"changed"`
+	Trigger    bool              `json:"trigger"`
+}
+
+func topicsHandler(w http.ResponseWriter, r *http.Request) {
+	s3Ctx, cancel := context.WithTimeout(r.Context(), s3Timeout)
+	config, err := loadConfig(s3Ctx, r.URL.Query().Get("config"))
+	cancel()
+	if err != nil {
+		status, body := configError(err)
+		writeJSON(w, status, body)
+		return
+	}
+
+	cacheCtx, cancel := context.WithTimeout(r.Context(), cacheTimeout)
+	defer cancel()
+	if config, err = expandTopics(cacheCtx, config); err != nil {
+		writeError(w, codeCacheRead, http.StatusInternalServerError, err)
+		return
+	}
+	snapshot, err := readTopicsFromCache(cacheCtx, config)
+	if err != nil {
+		writeError(w, codeCacheRead, http.StatusInternalServerError, err)
+		return
+	}
+	applyValueSize(cacheCtx, config, snapshot)
+	rejectMalformedVTQ(config, snapshot)
+	if config.Flatten {
+		config, snapshot = flattenSnapshot(config, snapshot)
+	}
+	transformSnapshot(config, snapshot)
+	roundSnapshot(config, snapshot)
+
+	lastSnapshotMu.Lock()
+	loadLastSnapshot(cacheCtx, config)
+	last := make(map[string]string, len(config.Topics))
+	for _, topic := range config.Topics {
+		if v, ok := lastSnapshot[config.stateKey(topic)]; ok {
+			last[topic] = v
+		}
+	}
+	lastSnapshotMu.Unlock()
+
+	line := r.URL.Query().Get("line")
+	topics := make([]topicState, 0, len(config.Topics))
+	for _, topic := range config.Topics {
+		uns := config.parseTopic(topic)
+		if line != "" && uns.Line != line {
+			continue
+		}
+
+		state := topicState{Topic: topic, UNS: uns.Levels, Tag: uns.Tag, Trigger: config.triggers(topic)}
+		snap := snapshot[topic]
+		if snap == nil {
+			snap = &topicSnapshot{}
+		}
+		state.Current = inspectValue(config, snap.Current)
+		state.Previous = inspectValue(config, snap.Previous)
+
+		lastVal, exists := last[topic]
+		if config.ChangeSource == changeSourcePrev {
+			lastVal, exists = snap.Previous, snap.Previous != ""
+		}
+		state.LastLogged = inspectValue(config, last[topic])
+		state.Changed = snap.Current != "" && (!exists || valueChanged(config.compareValue(lastVal),
+			config.compareValue(snap.Current), config.deadbandFor(uns.Tag), config.Counters[uns.Tag].Width))
+
+		topics = append(topics, state)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"table":  config.Table,
+		"topics": topics,
+	})
+}
+
+// inspectValue parses a raw value as it would be stored, nil when empty.
+func inspectValue(config *pglogConfig, raw string) interface{} {
+	if raw == "" {
+		return nil
+	}
+	return parseValue(config.compareValue(raw))
+}
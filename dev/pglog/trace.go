@@ -0,0 +1,93 @@
+package function
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ── Trace Exemplars ─────────────────────────────────────────────────
+// When a request arrives with a trace context — a W3C traceparent header,
+// or X-Cloud-Trace-Context as set by Google's front ends — its trace ID
+// is attached as an exemplar to pglog_rows_inserted_total, so a spike in
+// Grafana links straight to a trace of a request that caused it.
+// CloudEvents use their traceparent extension attribute.
+//
+// Exemplars are only exposed in the OpenMetrics format, which /metrics
+// serves to scrapers that ask for it. Without a trace context the
+// counter is incremented as before.
+
+type traceIDKey struct{}
+
+// withTraceID returns r with the trace ID of its trace headers, if any, in
+// the context.
+func withTraceID(r *http.Request) *http.Request {
+	id := parseTraceparent(r.Header.Get("traceparent"))
+	if id == "" {
+		id = parseCloudTraceContext(r.Header.Get("X-Cloud-Trace-Context"))
+	}
+	if id == "" {
+		return r
+	}
+	return r.WithContext(contextWithTraceID(r.Context(), id))
+}
+
+func contextWithTraceID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+func traceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// parseTraceparent returns the trace ID of a W3C traceparent value
+// ("00-{32 hex trace id}-{16 hex span id}-{2 hex flags}").
+func parseTraceparent(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+	return validTraceID(parts[1])
+}
+
+// parseCloudTraceContext returns the trace ID of an X-Cloud-Trace-Context
+// value ("{32 hex trace id}/{span id};o={flags}").
+func parseCloudTraceContext(header string) string {
+	id, _, _ := strings.Cut(header, "/")
+	return validTraceID(strings.ToLower(strings.TrimSpace(id)))
+}
+
+// validTraceID returns id if it is 32 lowercase hex digits and not all
+// zero, else "".
+func validTraceID(id string) string {
+	if len(id) != 32 || strings.Trim(id, "0") == "" {
+		return ""
+	}
+	for i := 0; i < len(id); i++ {
+		if !(id[i] >= '0' && id[i] <= '9' || id[i] >= 'a' && id[i] <= 'f') {
+			return ""
+		}
+	}
+	return id
+}
+
+// countInserted adds n to pglog_rows_inserted_total, with the request's
+// trace ID as exemplar when there is one.
+func countInserted(ctx context.Context, n int) {
+	if n <= 0 {
+		return
+	}
+	if id := traceIDFromContext(ctx); id != "" {
+		if adder, ok := metricRowsInserted.(prometheus.ExemplarAdder); ok {
+			adder.AddWithExemplar(float64(n), prometheus.Labels{"trace_id": id})
+			return
+		}
+	}
+	metricRowsInserted.Add(float64(n))
+}
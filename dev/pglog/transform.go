@@ -0,0 +1,80 @@
+package function
+
+import "fmt"
+
+// ── Transforms ──────────────────────────────────────────────────────
+// Numeric readings can be converted to engineering units per tag before
+// anything else looks at them:
+//
+//	"transform": {
+//	  "pressure": { "factor": 0.00153, "offset": -1.25 },
+//	  "period":   { "invert": true, "factor": 60 },
+//	  "level":    { "factor": 0.1, "clamp": { "min": 0, "max": 100 } }
+//	}
+//
+// In order: invert (1/raw, skipped for 0), raw * factor + offset (factor
+// defaults to 1), clamp to [min, max]. Like rounding (applied after the
+// transform, see round.go) this changes both the compared and the stored
+// value. Non-numeric values are left alone; vtq payloads have their
+// "value" transformed.
+
+type tagTransform struct {
+	Factor *float64   `json:"factor,omitempty"`
+	Offset float64    `json:"offset,omitempty"`
+	Invert bool       `json:"invert,omitempty"`
+	Clamp  *tagBounds `json:"clamp,omitempty"`
+}
+
+func validateTransform(config *pglogConfig) error {
+	for tag, t := range config.Transform {
+		if t.Clamp != nil && t.Clamp.Min != nil && t.Clamp.Max != nil && *t.Clamp.Min > *t.Clamp.Max {
+			return fmt.Errorf("transform.%s: clamp min is greater than max", tag)
+		}
+	}
+	return nil
+}
+
+// apply transforms a numeric value.
+func (t tagTransform) apply(v float64) float64 {
+	if t.Invert && v != 0 {
+		v = 1 / v
+	}
+	if t.Factor != nil {
+		v *= *t.Factor
+	}
+	v += t.Offset
+	if t.Clamp != nil {
+		if t.Clamp.Min != nil && v < *t.Clamp.Min {
+			v = *t.Clamp.Min
+		}
+		if t.Clamp.Max != nil && v > *t.Clamp.Max {
+			v = *t.Clamp.Max
+		}
+	}
+	return v
+}
+
+// transformSnapshot transforms the current and previous reading of every
+// topic with a transform.
+func transformSnapshot(config *pglogConfig, snapshot map[string]*topicSnapshot) {
+	if len(config.Transform) == 0 {
+		return
+	}
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		t, ok := config.Transform[config.parseTopic(topic).Tag]
+		if snap == nil || !ok {
+			continue
+		}
+		snap.Current = config.mapReading(snap.Current, t.apply)
+		snap.Previous = config.mapReading(snap.Previous, t.apply)
+	}
+}
+
+// transformValue transforms an already extracted (compare) value.
+func (c *pglogConfig) transformValue(tag, value string) string {
+	if t, ok := c.Transform[tag]; ok {
+		return mapNumber(value, t.apply)
+	}
+	return value
+}
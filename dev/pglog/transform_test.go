@@ -0,0 +1,87 @@
+package function
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTransformSnapshot(t *testing.T) {
+	const prefix = "v1.0/acme/factory1/mixing/line1/"
+	tests := []struct {
+		name         string
+		transform    string
+		tag          string
+		current      string
+		previous     string
+		wantCurrent  string
+		wantPrevious string
+	}{
+		{"factor and offset", `{"pressure": {"factor": 2, "offset": -1}}`, "pressure", "10", "5", "19", "9"},
+		{"offset only", `{"temp": {"offset": -273.15}}`, "temp", "300", "", "26.850000000000023", ""},
+		{"invert then factor", `{"period": {"invert": true, "factor": 60}}`, "period", "0.5", "", "120", ""},
+		{"invert skips zero", `{"period": {"invert": true, "factor": 60}}`, "period", "0", "", "0", ""},
+		{"clamp max", `{"level": {"factor": 0.1, "clamp": {"min": 0, "max": 100}}}`, "level", "1500", "", "100", ""},
+		{"clamp min", `{"level": {"factor": 0.1, "clamp": {"min": 0, "max": 100}}}`, "level", "-20", "", "0", ""},
+		{"other tag", `{"pressure": {"factor": 2}}`, "temp", "10", "", "10", ""},
+		{"non-numeric", `{"state": {"factor": 2}}`, "state", "RUNNING", "3", "RUNNING", "6"},
+		{"empty", `{"pressure": {"factor": 2}}`, "pressure", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		topic := prefix + tt.tag
+		config := testConfig(t, `{"topics": ["`+topic+`"], "transform": `+tt.transform+`}`)
+		snapshot := map[string]*topicSnapshot{topic: {Current: tt.current, Previous: tt.previous}}
+
+		transformSnapshot(config, snapshot)
+		if got := snapshot[topic]; got.Current != tt.wantCurrent || got.Previous != tt.wantPrevious {
+			t.Errorf("%s: transformed to %q/%q, want %q/%q", tt.name, got.Current, got.Previous, tt.wantCurrent, tt.wantPrevious)
+		}
+	}
+}
+
+func TestTransformVTQ(t *testing.T) {
+	const topic = "v1.0/acme/factory1/mixing/line1/pressure"
+	config := testConfig(t, `{"topics": ["`+topic+`"], "value_schema": "vtq", "transform": {"pressure": {"factor": 10}}}`)
+	snapshot := map[string]*topicSnapshot{topic: {Current: `{"value": 1.5, "quality": "GOOD"}`}}
+
+	transformSnapshot(config, snapshot)
+	if want := `{"quality":"GOOD","value":15}`; snapshot[topic].Current != want {
+		t.Errorf("transformed to %q, want %q", snapshot[topic].Current, want)
+	}
+}
+
+// transformedChangeTests compare transformed values; the topic's tag is
+// "temp".
+var transformedChangeTests = []struct {
+	name        string
+	config      string
+	current     string
+	previous    string
+	wantChanged []string
+	wantValue   interface{}
+}{
+	{"factor and offset", `"transform": {"temp": {"factor": 2, "offset": -1}}`, "10", "5", []string{"temp"}, 19.0},
+	{"equal once clamped", `"transform": {"temp": {"factor": 0.1, "clamp": {"max": 100}}}`, "1500", "1200", nil, 100.0},
+	{"equal once transformed and rounded", `"transform": {"temp": {"offset": -273.15}}, "round": {"temp": 1}`, "300.04", "300.01", nil, 26.9},
+	{"other tag", `"transform": {"pressure": {"factor": 2}}`, "10", "5", []string{"temp"}, 10.0},
+}
+
+func TestTransformedChanges(t *testing.T) {
+	for _, tt := range transformedChangeTests {
+		config := testConfig(t, `{"topics": ["v1.0/acme/factory1/mixing/line1/temp"], "change_source": "prev", `+tt.config+`}`)
+		changed, value := preparedChanges(t, config, tt.current, tt.previous)
+		if !slices.Equal(changed, tt.wantChanged) || value != tt.wantValue {
+			t.Errorf("%s: changed %v, value %v, want %v, %v", tt.name, changed, value, tt.wantChanged, tt.wantValue)
+		}
+	}
+}
+
+func TestTransformedChangesDryRun(t *testing.T) {
+	testCache(t)
+	for _, tt := range transformedChangeTests {
+		config := testConfig(t, `{"topics": ["v1.0/acme/factory1/mixing/line1/temp"], "change_source": "prev", `+tt.config+`}`)
+		changed, value := dryRunChanges(t, config, tt.current, tt.previous)
+		if !slices.Equal(changed, tt.wantChanged) || value != tt.wantValue {
+			t.Errorf("%s: runLog reported changed %v, value %v, want %v, %v", tt.name, changed, value, tt.wantChanged, tt.wantValue)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ── Trigger Flags ───────────────────────────────────────────────────
+// A topic can be listed as an object to keep its value in the snapshot
+// without its own changes logging a row (e.g. a slowly drifting ambient
+// reading):
+//
+//	"topics": [
+//	  "v1.0/acme/factory1/mixing/line1/temperature",
+//	  { "topic": "v1.0/acme/factory1/mixing/line1/ambient", "trigger": false }
+//	]
+//
+// Plain strings and objects without "trigger" trigger as before. On a
+// wildcard topic the flag applies to every topic it expands to.
+
+type topicEntry struct {
+	Topic   string `json:"topic"`
+	Trigger *bool  `json:"trigger"`
+}
+
+func (e *topicEntry) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &e.Topic)
+	}
+	type plain topicEntry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// UnmarshalJSON accepts both topic forms, keeping Topics a plain list and
+// collecting the non-triggering topics in NoTrigger.
+func (c *pglogConfig) UnmarshalJSON(data []byte) error {
+	type plain pglogConfig
+	aux := struct {
+		*plain
+		Topics []topicEntry `json:"topics"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	c.Topics = nil
+	c.NoTrigger = nil
+	for _, entry := range aux.Topics {
+		c.Topics = append(c.Topics, entry.Topic)
+		if entry.Trigger != nil && !*entry.Trigger {
+			if c.NoTrigger == nil {
+				c.NoTrigger = make(map[string]bool)
+			}
+			c.NoTrigger[entry.Topic] = true
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes non-triggering topics in the object form, so the
+// /reload-config response shows the flags. The database password is
+// redacted.
+func (c pglogConfig) MarshalJSON() ([]byte, error) {
+	type plain pglogConfig
+	c.Database = redactDatabase(c.Database)
+	topics := make([]interface{}, len(c.Topics))
+	for i, topic := range c.Topics {
+		topics[i] = topic
+		if c.NoTrigger[topic] {
+			topics[i] = map[string]interface{}{"topic": topic, "trigger": false}
+		}
+	}
+	return json.Marshal(struct {
+		plain
+		Topics []interface{} `json:"topics"`
+	}{plain: plain(c), Topics: topics})
+}
+
+// triggers reports whether a change of the topic logs a row.
+func (c *pglogConfig) triggers(topic string) bool {
+	if c.NoTrigger[topic] {
+		return false
+	}
+	for pattern := range c.NoTrigger {
+		if isWildcardTopic(pattern) && matchTopic(pattern, topic) {
+			return false
+		}
+	}
+	return true
+}
+
+// ── Trigger Mode ────────────────────────────────────────────────────
+// "trigger_mode" decides whether the changed tags log a row:
+//
+//	"any" (default)  any triggering tag changed
+//	"all"            every triggering tag changed since the last row
+//	"tag"            "trigger_tag" changed; other changes ride along
+//
+// The row always lists every changed tag; the response reports the mode
+// and the tags that satisfied it.
+
+const (
+	triggerModeAny = "any"
+	triggerModeAll = "all"
+	triggerModeTag = "tag"
+)
+
+func validateTriggerMode(config *pglogConfig) error {
+	switch config.TriggerMode {
+	case triggerModeAny, triggerModeAll:
+		if config.TriggerTag != "" {
+			return fmt.Errorf("trigger_tag needs trigger_mode %q", triggerModeTag)
+		}
+	case triggerModeTag:
+		if config.TriggerTag == "" {
+			return fmt.Errorf("trigger_mode %q needs trigger_tag", triggerModeTag)
+		}
+	default:
+		return fmt.Errorf("trigger_mode must be %q, %q or %q", triggerModeAny, triggerModeAll, triggerModeTag)
+	}
+	return nil
+}
+
+// applyTriggerMode returns the changes if they satisfy the trigger mode
+// (nil otherwise), and the tags that satisfied it (empty otherwise).
+func (c *pglogConfig) applyTriggerMode(changed []string) ([]string, []string) {
+	changedTags := make(map[string]bool, len(changed))
+	var tags []string
+	for _, entry := range changed {
+		if tag := tagOfChange(entry); !changedTags[tag] {
+			changedTags[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	var required []string
+	switch c.TriggerMode {
+	case triggerModeAll:
+		for _, topic := range c.Topics {
+			if tag := c.parseTopic(topic).Tag; c.triggers(topic) && !c.aggregates(tag) {
+				required = append(required, tag)
+			}
+		}
+	case triggerModeTag:
+		required = []string{c.TriggerTag}
+	default:
+		return changed, tags
+	}
+
+	for _, tag := range required {
+		if !changedTags[tag] {
+			return nil, []string{}
+		}
+	}
+	return changed, required
+}
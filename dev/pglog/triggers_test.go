@@ -0,0 +1,56 @@
+package function
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestConfigTopicForms(t *testing.T) {
+	tests := []struct {
+		name          string
+		topics        string
+		wantTopics    []string
+		wantNoTrigger []string
+	}{
+		{"strings", `["v1.0/a/temp", "v1.0/a/rpm"]`, []string{"v1.0/a/temp", "v1.0/a/rpm"}, nil},
+		{"mixed", `["v1.0/a/temp", {"topic": "v1.0/a/ambient", "trigger": false}]`, []string{"v1.0/a/temp", "v1.0/a/ambient"}, []string{"v1.0/a/ambient"}},
+		{"object triggering", `[{"topic": "v1.0/a/temp", "trigger": true}]`, []string{"v1.0/a/temp"}, nil},
+		{"object without trigger", `[{"topic": "v1.0/a/temp"}]`, []string{"v1.0/a/temp"}, nil},
+	}
+	for _, tt := range tests {
+		var config pglogConfig
+		if err := json.Unmarshal([]byte(`{"table": "t", "topics": `+tt.topics+`}`), &config); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if config.Table != "t" || !slices.Equal(config.Topics, tt.wantTopics) {
+			t.Errorf("%s: table %q, topics %v, want %v", tt.name, config.Table, config.Topics, tt.wantTopics)
+		}
+		for _, topic := range config.Topics {
+			if want := !slices.Contains(tt.wantNoTrigger, topic); config.triggers(topic) != want {
+				t.Errorf("%s: triggers(%q) = %v, want %v", tt.name, topic, !want, want)
+			}
+		}
+
+		// The object form survives a round trip through MarshalJSON
+		out, err := json.Marshal(config)
+		if err != nil {
+			t.Errorf("%s: marshal: %v", tt.name, err)
+			continue
+		}
+		var again pglogConfig
+		if err := json.Unmarshal(out, &again); err != nil || !slices.Equal(again.Topics, config.Topics) || len(again.NoTrigger) != len(tt.wantNoTrigger) {
+			t.Errorf("%s: round trip gave %v %v (%v)", tt.name, again.Topics, again.NoTrigger, err)
+		}
+	}
+}
+
+func TestConfigTopicFormsInvalid(t *testing.T) {
+	for _, topics := range []string{`[1]`, `[{"topic": 1}]`, `[{"topic": "v1.0/a/temp", "trigger": "no"}]`, `"v1.0/a/temp"`} {
+		var config pglogConfig
+		if err := json.Unmarshal([]byte(`{"topics": `+topics+`}`), &config); err == nil {
+			t.Errorf("topics %s: no error", topics)
+		}
+	}
+}
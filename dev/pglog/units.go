@@ -0,0 +1,51 @@
+package function
+
+import "fmt"
+
+// ── Engineering Units ───────────────────────────────────────────────
+// Topics that carry the unit as their last segment
+//
+//	v1.0/acme/factory1/mixing/line1/temperature/degC
+//
+// can split it off the tag with "unit_segment": "last": the tag becomes
+// "temperature" (which is what values, deadband, bounds etc. are keyed
+// by) and the unit "degC". A tag with a single segment has no unit.
+// With "value_schema": "vtq", "unit_from_value": true reads a "unit"
+// field from the payload instead, falling back to the topic segment.
+//
+// Either option adds a "unit" TEXT column holding the unit of the row's
+// tag. Both are off by default.
+
+const unitSegmentLast = "last"
+
+func validateUnits(config *pglogConfig) error {
+	if config.UnitSegment != "" && config.UnitSegment != unitSegmentLast {
+		return fmt.Errorf("unit_segment must be empty or %q", unitSegmentLast)
+	}
+	if config.UnitFromValue && config.ValueSchema != valueSchemaVTQ {
+		return fmt.Errorf("unit_from_value requires value_schema %q", valueSchemaVTQ)
+	}
+	return nil
+}
+
+// hasUnits reports whether rows carry a unit column.
+func (c *pglogConfig) hasUnits() bool {
+	return c.UnitSegment != "" || c.UnitFromValue
+}
+
+// tagUnit returns the unit of a tag from its cache payload or topic.
+func (c *pglogConfig) tagUnit(snapshot map[string]*topicSnapshot, tag string) string {
+	for _, topic := range c.Topics {
+		fields := c.parseTopic(topic)
+		if fields.Tag != tag {
+			continue
+		}
+		if snap := snapshot[topic]; c.UnitFromValue && snap != nil {
+			if reading, ok := parseVTQ(snap.Current); ok && reading.Unit != "" {
+				return reading.Unit
+			}
+		}
+		return fields.Unit
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package function
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ── UNS Schema ──────────────────────────────────────────────────────
+// The topic hierarchy defaults to ISA-95 style paths:
+//
+//	v1.0/{enterprise}/{site}/{area}/{line}/{tag...}
+//
+// Deployments with a different depth declare their levels in order:
+//
+//	"uns_schema": ["version", "enterprise", "site", "line", "tag"]
+//
+// "tag" must be the last level and takes all remaining segments.
+// "version" is skipped; every other level becomes a TEXT column of the
+// log table. New levels are added to an existing table (see migrate.go)
+// with existing rows set to "unknown"; dropped levels are still NOT NULL,
+// so removing one needs a new table or a manual migration.
+
+var defaultUNSSchema = []string{"version", "enterprise", "site", "area", "line", "tag"}
+
+// unsSchema returns the configured topic levels, or the default.
+func (c *pglogConfig) unsSchema() []string {
+	if len(c.UNSSchema) == 0 {
+		return defaultUNSSchema
+	}
+	return c.UNSSchema
+}
+
+// levelColumns returns the levels stored as columns, in schema order.
+func (c *pglogConfig) levelColumns() []string {
+	var levels []string
+	for _, name := range c.unsSchema() {
+		if name != "version" && name != "tag" {
+			levels = append(levels, name)
+		}
+	}
+	return levels
+}
+
+// parseTopic maps the segments of a topic onto the schema levels.
+// Levels missing from the topic (or the schema) are "unknown".
+func (c *pglogConfig) parseTopic(topic string) unsFields {
+	parts := strings.Split(topic, "/")
+
+	fields := unsFields{
+		Enterprise: "unknown",
+		Site:       "unknown",
+		Area:       "unknown",
+		Line:       "unknown",
+		Tag:        "unknown",
+		Levels:     make(map[string]string),
+	}
+
+	for i, name := range c.unsSchema() {
+		value := "unknown"
+		if name == "tag" {
+			// Tag can be multi-level (e.g. "cell1/temperature")
+			if len(parts) > i {
+				value = strings.Join(parts[i:], "/")
+			}
+		} else if len(parts) > i {
+			value = parts[i]
+		}
+
+		switch name {
+		case "version":
+			continue
+		case "tag":
+			fields.Tag = value
+			continue
+		case "enterprise":
+			fields.Enterprise = value
+		case "site":
+			fields.Site = value
+		case "area":
+			fields.Area = value
+		case "line":
+			fields.Line = value
+		}
+		fields.Levels[name] = value
+	}
+
+	// See units.go
+	if c.UnitSegment == unitSegmentLast {
+		if i := strings.LastIndex(fields.Tag, "/"); i > 0 {
+			fields.Tag, fields.Unit = fields.Tag[:i], fields.Tag[i+1:]
+		}
+	}
+
+	return fields
+}
+
+// lineKey joins the level values of a topic, e.g. acme/factory1/mixing/line1.
+func (c *pglogConfig) lineKey(uns unsFields) string {
+	var levels []string
+	for _, level := range c.levelColumns() {
+		levels = append(levels, uns.Levels[level])
+	}
+	return strings.Join(levels, "/")
+}
+
+// levelPlaceholder matches {level} placeholders in key and topic templates.
+var levelPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// expandLevels replaces {level} placeholders with the topic's UNS levels.
+// Placeholders that don't name a level are left as they are.
+func expandLevels(template string, uns unsFields) string {
+	return levelPlaceholder.ReplaceAllStringFunc(template, func(m string) string {
+		if value, ok := uns.Levels[m[1:len(m)-1]]; ok {
+			return value
+		}
+		return m
+	})
+}
+
+func validateUNSSchema(schema []string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	if schema[len(schema)-1] != "tag" {
+		return fmt.Errorf("uns_schema: the last level must be \"tag\"")
+	}
+
+	seen := make(map[string]bool)
+	for i, name := range schema {
+		if err := validateIdentifier(name); err != nil {
+			return fmt.Errorf("uns_schema[%d]: %v", i, err)
+		}
+		lower := strings.ToLower(name)
+		if seen[lower] {
+			return fmt.Errorf("uns_schema[%d]: duplicate level %q", i, name)
+		}
+		seen[lower] = true
+		if lower != "version" && lower != "tag" && reservedColumns[lower] {
+			return fmt.Errorf("uns_schema[%d]: %q is a reserved column", i, name)
+		}
+	}
+	return nil
+}
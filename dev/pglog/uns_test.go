@@ -0,0 +1,58 @@
+package function
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTopic(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     []string
+		topic      string
+		wantTag    string
+		wantLevels map[string]string
+	}{
+		{"default schema", nil, "v1.0/acme/factory1/mixing/line1/temperature", "temperature",
+			map[string]string{"enterprise": "acme", "site": "factory1", "area": "mixing", "line": "line1"}},
+		{"multi-level tag", nil, "v1.0/acme/factory1/mixing/line1/cell1/temperature", "cell1/temperature",
+			map[string]string{"enterprise": "acme", "site": "factory1", "area": "mixing", "line": "line1"}},
+		{"short topic", nil, "v1.0/acme/factory1", "unknown",
+			map[string]string{"enterprise": "acme", "site": "factory1", "area": "unknown", "line": "unknown"}},
+		{"custom schema", []string{"version", "enterprise", "site", "line", "tag"}, "v1.0/acme/factory1/line1/temperature", "temperature",
+			map[string]string{"enterprise": "acme", "site": "factory1", "line": "line1"}},
+		{"no version", []string{"plant", "cell", "tag"}, "north/cell7/rpm", "rpm",
+			map[string]string{"plant": "north", "cell": "cell7"}},
+	}
+	for _, tt := range tests {
+		config := &pglogConfig{UNSSchema: tt.schema}
+		got := config.parseTopic(tt.topic)
+		if got.Tag != tt.wantTag || !reflect.DeepEqual(got.Levels, tt.wantLevels) {
+			t.Errorf("%s: parseTopic(%q) = %q %v, want %q %v", tt.name, tt.topic, got.Tag, got.Levels, tt.wantTag, tt.wantLevels)
+		}
+	}
+}
+
+func TestValidateUNSSchema(t *testing.T) {
+	tests := []struct {
+		schema  []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"version", "enterprise", "site", "line", "tag"}, false},
+		{[]string{"plant", "tag"}, false},
+		{[]string{"tag"}, false},
+		{[]string{"enterprise", "site"}, true},
+		{[]string{"tag", "enterprise"}, true},
+		{[]string{"site", "Site", "tag"}, true},
+		{[]string{"values", "tag"}, true},
+		{[]string{"unit", "tag"}, true},
+		{[]string{"my-site", "tag"}, true},
+	}
+	for _, tt := range tests {
+		err := validateUNSSchema(tt.schema)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateUNSSchema(%v) = %v, want error %v", tt.schema, err, tt.wantErr)
+		}
+	}
+}
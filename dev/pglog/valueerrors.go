@@ -0,0 +1,123 @@
+package function
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ── Captured Value Errors ───────────────────────────────────────────
+// With "capture_errors": true every value that is excluded from a row
+// because it is malformed is also recorded in {table}_errors:
+//
+//	SELECT topic, raw_value, reason FROM uns_log_errors ORDER BY logged_at DESC
+//
+// Captured are values that don't decode per value_encoding, payloads that
+// aren't vtq objects under value_schema "vtq" (only excluded with
+// capture_errors), readings outside their bounds, values failing their
+// schema and oversized values (truncated). Non-UTF-8 values (binary
+// protobuf) are stored base64-encoded. The row is logged without the
+// offending values; a failed capture is only logged as a warning.
+
+const errorsSuffix = "_errors"
+
+// Reasons recorded in {table}_errors.
+const (
+	reasonUndecodable = "undecodable"
+	reasonNotVTQ      = "not_vtq"
+	reasonOutOfRange  = "out_of_range"
+)
+
+func errorsTable(table string) string {
+	return table + errorsSuffix
+}
+
+func validateCaptureErrors(config *pglogConfig) error {
+	if !config.CaptureErrors {
+		return nil
+	}
+	if config.CacheLayout == cacheLayoutStream {
+		return fmt.Errorf("capture_errors can't be combined with cache_layout %q", cacheLayoutStream)
+	}
+	if err := validateIdentifier(errorsTable(config.Table)); err != nil {
+		return fmt.Errorf("capture_errors: %v", err)
+	}
+	return nil
+}
+
+// ensureErrorsTable creates {table}_errors.
+func ensureErrorsTable(ctx context.Context, config *pglogConfig) error {
+	table := errorsTable(config.Table)
+	pool, err := dbFor(config)
+	if err != nil {
+		return err
+	}
+	_, err = pool.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id          BIGSERIAL    PRIMARY KEY,
+			logged_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+			tenant      TEXT         NOT NULL DEFAULT '',
+			topic       TEXT         NOT NULL,
+			tag         TEXT         NOT NULL,
+			raw_value   TEXT         NOT NULL,
+			reason      TEXT         NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS %s ON %s (logged_at);
+	`, quoteIdent(table), quoteIdent("idx_"+table+"_time"), quoteIdent(table)))
+	return err
+}
+
+// rejectMalformedVTQ excludes current values that aren't vtq payloads.
+func rejectMalformedVTQ(config *pglogConfig, snapshot map[string]*topicSnapshot) {
+	if !config.CaptureErrors || config.ValueSchema != valueSchemaVTQ {
+		return
+	}
+	for _, snap := range snapshot {
+		if snap.Current == "" {
+			continue
+		}
+		if _, ok := parseVTQ(snap.Current); !ok {
+			snap.Rejected, snap.Reason = snap.Current, reasonNotVTQ
+			snap.Current = ""
+		}
+	}
+}
+
+// captureErrors writes the rejected values of the snapshot to
+// {table}_errors and returns how many were captured.
+func captureErrors(ctx context.Context, config *pglogConfig, snapshot map[string]*topicSnapshot) int {
+	if !config.CaptureErrors {
+		return 0
+	}
+
+	batch := &pgx.Batch{}
+	query := fmt.Sprintf("INSERT INTO %s (tenant, topic, tag, raw_value, reason) VALUES ($1, $2, $3, $4, $5)",
+		quoteIdent(errorsTable(config.Table)))
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		if snap == nil || snap.Reason == "" {
+			continue
+		}
+		raw := snap.Rejected
+		if !utf8.ValidString(raw) {
+			raw = base64.StdEncoding.EncodeToString([]byte(raw))
+		}
+		batch.Queue(query, tenantID, topic, config.parseTopic(topic).Tag, raw, snap.Reason)
+	}
+	if batch.Len() == 0 {
+		return 0
+	}
+
+	pool, err := dbFor(config)
+	if err == nil {
+		err = pool.SendBatch(ctx, batch).Close()
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to capture value errors", "table", errorsTable(config.Table), "error", err)
+		return 0
+	}
+	return batch.Len()
+}
@@ -0,0 +1,91 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ── Value Size Limit ────────────────────────────────────────────────
+// A misconfigured upstream occasionally writes a whole file into a cache
+// key. Values larger than "max_value_bytes" (default 1 MiB) are kept out
+// of the row:
+//
+//	"max_value_bytes": 65536,
+//	"oversized": "reject"
+//
+// "reject" (the default) excludes the value before change detection, as
+// if it were missing; "truncate" stores its first max_value_bytes bytes as
+// a JSON string instead (not with value_schema "vtq", where that wouldn't
+// be a payload). Either way the tag is listed as "oversized" in the
+// response and, with capture_errors, recorded in {table}_errors with the
+// truncated value. The limit applies to the raw cache value, so it's
+// checked before flatten, transforms and schemas parse it.
+
+const (
+	defaultMaxValueBytes = 1 << 20
+
+	oversizedReject   = "reject"
+	oversizedTruncate = "truncate"
+
+	reasonOversized = "oversized"
+)
+
+func validateValueSize(config *pglogConfig) error {
+	if config.MaxValueBytes < 1 {
+		return fmt.Errorf("max_value_bytes must be positive")
+	}
+	switch config.Oversized {
+	case oversizedReject, oversizedTruncate:
+	default:
+		return fmt.Errorf("oversized must be %q or %q", oversizedReject, oversizedTruncate)
+	}
+	if config.Oversized == oversizedTruncate && config.ValueSchema == valueSchemaVTQ {
+		return fmt.Errorf("oversized %q can't be combined with value_schema %q", oversizedTruncate, valueSchemaVTQ)
+	}
+	return nil
+}
+
+// truncateValue returns the first n bytes of s, cut back to a rune
+// boundary.
+func truncateValue(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for i := 1; i < utf8.UTFMax && len(s) > 0; i++ {
+		if r, size := utf8.DecodeLastRuneInString(s); r != utf8.RuneError || size != 1 {
+			break
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// applyValueSize rejects or truncates the current values over the limit
+// and returns their tags.
+func applyValueSize(ctx context.Context, config *pglogConfig, snapshot map[string]*topicSnapshot) []string {
+	var oversized []string
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		if snap == nil || len(snap.Current) <= config.MaxValueBytes {
+			continue
+		}
+
+		tag := config.parseTopic(topic).Tag
+		logger.WarnContext(ctx, "Value exceeds max_value_bytes", "topic", topic,
+			"bytes", len(snap.Current), "max_value_bytes", config.MaxValueBytes)
+		oversized = append(oversized, tag)
+
+		truncated := truncateValue(snap.Current, config.MaxValueBytes)
+		snap.Rejected, snap.Reason = truncated, reasonOversized
+		if config.Oversized == oversizedTruncate {
+			b, _ := json.Marshal(truncated)
+			snap.Current = string(b)
+		} else {
+			snap.Current = ""
+		}
+	}
+	return oversized
+}
@@ -0,0 +1,149 @@
+package function
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Value/Timestamp/Quality Payloads ────────────────────────────────
+// With "value_schema": "vtq" each cache value is a JSON object:
+//
+//	{"value": 72.5, "ts": "2026-02-21T15:10:44Z", "quality": "GOOD"}
+//
+// Only "value" is compared for change detection and stored in the values
+// JSONB. Per-tag quality is stored in the "quality" JSONB column and the
+// trigger tag's timestamp in "source_ts" (NOW() when the payload has no
+// ts). "ts" may be an RFC 3339 string or epoch milliseconds.
+
+const valueSchemaVTQ = "vtq"
+
+type vtqReading struct {
+	Value   json.RawMessage `json:"value"`
+	TS      json.RawMessage `json:"ts"`
+	Quality string          `json:"quality"`
+	Unit    string          `json:"unit"` // see units.go
+}
+
+// parseVTQ decodes a vtq payload; ok is false when it isn't one.
+func parseVTQ(raw string) (vtqReading, bool) {
+	var reading vtqReading
+	if err := json.Unmarshal([]byte(raw), &reading); err != nil || len(reading.Value) == 0 {
+		return vtqReading{}, false
+	}
+	return reading, true
+}
+
+// timestamp returns the payload's source timestamp, if it has one.
+func (r vtqReading) timestamp() (time.Time, bool) {
+	if len(r.TS) == 0 {
+		return time.Time{}, false
+	}
+
+	var text string
+	if err := json.Unmarshal(r.TS, &text); err == nil {
+		ts, err := time.Parse(time.RFC3339Nano, text)
+		return ts, err == nil
+	}
+
+	ms, err := strconv.ParseInt(string(r.TS), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// compareValue returns the part of a raw cache value that is compared and
+// stored: the "value" field for vtq payloads, the raw value otherwise.
+func (c *pglogConfig) compareValue(raw string) string {
+	if c.ValueSchema == valueSchemaVTQ {
+		if reading, ok := parseVTQ(raw); ok {
+			return string(reading.Value)
+		}
+	}
+	return raw
+}
+
+// mapReading applies fn to a raw cache value if it is numeric: the value
+// itself, or the "value" field of a vtq payload. Anything else is
+// returned unchanged.
+func (c *pglogConfig) mapReading(raw string, fn func(float64) float64) string {
+	if raw == "" || c.ValueSchema != valueSchemaVTQ {
+		return mapNumber(raw, fn)
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil || payload["value"] == nil {
+		return raw
+	}
+	mapped := mapNumber(string(payload["value"]), fn)
+	if mapped == string(payload["value"]) {
+		return raw
+	}
+	payload["value"] = json.RawMessage(mapped)
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return raw
+	}
+	return string(out)
+}
+
+// mapNumber applies fn to s if it is a finite number (and the result is
+// too), returning s unchanged otherwise.
+func mapNumber(s string, fn func(float64) float64) string {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return s
+	}
+	mapped := fn(f)
+	if math.IsNaN(mapped) || math.IsInf(mapped, 0) {
+		return s
+	}
+	return strconv.FormatFloat(mapped, 'f', -1, 64)
+}
+
+// timestampAdvanced reports whether the current vtq payload has a newer
+// source timestamp than the last one. Payloads without a readable ts
+// count as advanced, leaving the decision to the value comparison.
+func timestampAdvanced(last, current string) bool {
+	lastReading, _ := parseVTQ(last)
+	currentReading, _ := parseVTQ(current)
+	lastTS, lastOK := lastReading.timestamp()
+	currentTS, currentOK := currentReading.timestamp()
+	if !lastOK || !currentOK {
+		return true
+	}
+	return currentTS.After(lastTS)
+}
+
+// vtqMetadata returns tag → quality for every topic, and the source
+// timestamp of the trigger tag (zero when absent).
+func vtqMetadata(config *pglogConfig, snapshot map[string]*topicSnapshot, triggerTag string) (map[string]string, time.Time) {
+	quality := make(map[string]string)
+	var sourceTS time.Time
+
+	for _, topic := range config.Topics {
+		snap := snapshot[topic]
+		if snap == nil || snap.Current == "" {
+			continue
+		}
+		reading, ok := parseVTQ(snap.Current)
+		if !ok {
+			continue
+		}
+
+		tag := config.parseTopic(topic).Tag
+		if reading.Quality != "" {
+			quality[tag] = reading.Quality
+		}
+		if tag == triggerTag {
+			if ts, ok := reading.timestamp(); ok {
+				sourceTS = ts
+			}
+		}
+	}
+
+	return quality, sourceTS
+}
@@ -0,0 +1,144 @@
+package function
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ── Webhook ─────────────────────────────────────────────────────────
+// With WEBHOOK_URL set every inserted row is also POSTed to it as JSON:
+// the change notification (see notify.go) plus the row's line. The body
+// is signed with WEBHOOK_SECRET, which is required:
+//
+//	X-Signature: sha256=<hex HMAC-SHA256 of the body>
+//
+// Deliveries run in the background with WEBHOOK_TIMEOUT_MS per attempt
+// and up to WEBHOOK_RETRIES retries (backing off from 500ms) on network
+// errors, 429 and 5xx. They never fail the request; a delivery that
+// gives up is logged and counted in pglog_webhook_errors_total. Shutdown
+// waits for deliveries in flight.
+
+const (
+	webhookSignatureHeader = "X-Signature"
+	webhookBackoff         = 500 * time.Millisecond
+)
+
+var (
+	webhookURL     string
+	webhookSecret  []byte
+	webhookRetries = envIntOrDefault("WEBHOOK_RETRIES", 2)
+	webhookClient  *http.Client
+	webhookWG      sync.WaitGroup
+)
+
+type webhookPayload struct {
+	changeNotification
+	Line string `json:"line"`
+}
+
+func initWebhook() {
+	webhookURL = envOrDefault("WEBHOOK_URL", "")
+	if webhookURL == "" {
+		return
+	}
+	webhookSecret = []byte(envOrDefault("WEBHOOK_SECRET", ""))
+	if len(webhookSecret) == 0 {
+		fatal("WEBHOOK_URL requires WEBHOOK_SECRET")
+	}
+	if webhookRetries < 0 {
+		fatal("Invalid WEBHOOK_RETRIES", "value", webhookRetries)
+	}
+	webhookClient = &http.Client{
+		Timeout: time.Duration(envIntOrDefault("WEBHOOK_TIMEOUT_MS", 5000)) * time.Millisecond,
+	}
+	logger.Info("Webhook notifications enabled", "url", webhookURL, "retries", webhookRetries)
+}
+
+// signWebhook returns the X-Signature value for a body.
+func signWebhook(body []byte) string {
+	mac := hmac.New(sha256.New, webhookSecret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// publishWebhook delivers a logged row to WEBHOOK_URL without blocking
+// the request.
+func publishWebhook(row logRow) {
+	if webhookClient == nil {
+		return
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		changeNotification: newChangeNotification(row),
+		Line:               row.Config.lineKey(row.UNS),
+	})
+	if err != nil {
+		metricWebhookErrors.Inc()
+		logger.Warn("Failed to encode webhook payload", "error", err)
+		return
+	}
+
+	webhookWG.Add(1)
+	go func() {
+		defer webhookWG.Done()
+		err := retryWithBackoff(webhookRetries, webhookBackoff, func() error {
+			return deliverWebhook(body)
+		})
+		if err != nil {
+			metricWebhookErrors.Inc()
+			logger.Warn("Failed to deliver webhook", "table", row.Config.Table, "error", err)
+		}
+	}()
+}
+
+// deliverWebhook POSTs one attempt. Client errors other than 429 are
+// not worth retrying, so they are logged and reported as delivered.
+func deliverWebhook(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, signWebhook(body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	metricWebhookErrors.Inc()
+	logger.Warn("Webhook rejected change", "status", resp.Status)
+	return nil
+}
+
+// waitWebhooks waits for deliveries in flight, until ctx is done.
+func waitWebhooks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		webhookWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,190 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ── Wildcard Topics ─────────────────────────────────────────────────
+// Config topics may use MQTT-style wildcards instead of listing every tag:
+//   v1.0/acme/factory1/mixing/line1/+   → every tag one level below line1
+//   v1.0/acme/factory1/mixing/line1/#   → every tag at any depth
+//
+// Wildcards are resolved by SCANning the data keys in the cache (see
+// keys.go for their template).
+// The expansion is cached per config (name or table, and cache prefix)
+// for the config TTL so invocations don't SCAN. An entry is reused while
+// the config's topic patterns are unchanged.
+
+const scanCount = 500
+
+type topicExpansion struct {
+	patterns []string
+	topics   []string
+	fetched  time.Time
+}
+
+var (
+	expansionMu sync.Mutex
+	expansions  = make(map[string]*topicExpansion) // cache prefix + config → expansion
+)
+
+func isWildcardTopic(topic string) bool {
+	return strings.ContainsAny(topic, "+#")
+}
+
+// validateWildcard checks that "+" and "#" occupy whole levels and that
+// "#" is only used as the last level.
+func validateWildcard(topic string) error {
+	levels := strings.Split(topic, "/")
+	for i, level := range levels {
+		if strings.ContainsAny(level, "+#") && len(level) != 1 {
+			return fmt.Errorf("topic %q: wildcards must occupy a whole level", topic)
+		}
+		if level == "#" && i != len(levels)-1 {
+			return fmt.Errorf("topic %q: '#' must be the last level", topic)
+		}
+	}
+	return nil
+}
+
+// expandTopics returns config with wildcard topics replaced by the
+// concrete topics currently present in the cache.
+func expandTopics(ctx context.Context, config *pglogConfig) (*pglogConfig, error) {
+	hasWildcard := false
+	for _, topic := range config.Topics {
+		if isWildcardTopic(topic) {
+			hasWildcard = true
+			break
+		}
+	}
+	if !hasWildcard {
+		return config, nil
+	}
+
+	key := config.cachePrefix() + "|" + config.stateID()
+	expansionMu.Lock()
+	e := expansions[key]
+	expansionMu.Unlock()
+	if e != nil && slices.Equal(e.patterns, config.Topics) && time.Since(e.fetched) < configTTL {
+		expanded := *config
+		expanded.Topics = e.topics
+		return &expanded, nil
+	}
+
+	// SCAN without the lock, so a slow cache doesn't hold up other configs;
+	// concurrent misses of one config each scan and the last one is kept
+
+	seen := make(map[string]bool)
+	var topics []string
+	for _, pattern := range config.Topics {
+		if !isWildcardTopic(pattern) {
+			if !seen[pattern] {
+				seen[pattern] = true
+				topics = append(topics, pattern)
+			}
+			continue
+		}
+
+		matches, err := scanTopics(ctx, config, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand %s: %w", pattern, err)
+		}
+		for _, topic := range matches {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+
+	expanded := *config
+	expanded.Topics = topics
+
+	expansionMu.Lock()
+	defer expansionMu.Unlock()
+	for k, e := range expansions {
+		if time.Since(e.fetched) >= configTTL {
+			delete(expansions, k)
+		}
+	}
+	expansions[key] = &topicExpansion{patterns: config.Topics, topics: topics, fetched: time.Now()}
+
+	return &expanded, nil
+}
+
+// scanTopics returns the sorted topics with a data key matching pattern.
+func scanTopics(ctx context.Context, config *pglogConfig, pattern string) ([]string, error) {
+	// Narrow the SCAN to the literal prefix before the first wildcard
+	literal := pattern[:strings.IndexAny(pattern, "+#")]
+	before, after := config.keyAffixes("data")
+	match := escapeGlob(before) + literal + "*" + escapeGlob(after)
+
+	var matches []string
+	collect := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, match, scanCount).Iterator()
+		for iter.Next(ctx) {
+			topic := strings.TrimSuffix(strings.TrimPrefix(iter.Val(), before), after)
+			if matchTopic(pattern, topic) {
+				matches = append(matches, topic)
+			}
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := cache.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return collect(ctx, node)
+		})
+	} else {
+		err = collect(ctx, cache)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// escapeGlob escapes the SCAN MATCH special characters in s.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// matchTopic reports whether topic matches an MQTT-style pattern.
+func matchTopic(pattern, topic string) bool {
+	patternLevels := strings.Split(pattern, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range patternLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(patternLevels) == len(topicLevels)
+}
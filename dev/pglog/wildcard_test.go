@@ -0,0 +1,59 @@
+package function
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestExpandTopicsScansUnlocked(t *testing.T) {
+	// A cache that accepts connections and never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	hung, err := newCacheClient("redis://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("newCacheClient: %v", err)
+	}
+	old := cache
+	cache = hung
+	t.Cleanup(func() {
+		cache = old
+		hung.Close()
+	})
+
+	config := testConfig(t, `{"name": "wildcard_hung", "topics": ["v1.0/acme/plant1/press/line1/+"]}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := expandTopics(ctx, config)
+		done <- err
+	}()
+
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("expandTopics didn't reach the cache")
+	}
+	if !expansionMu.TryLock() {
+		t.Fatal("expansion cache locked during the SCAN")
+	}
+	expansionMu.Unlock()
+
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("expandTopics on a hung cache succeeded")
+	}
+}